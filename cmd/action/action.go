@@ -1,6 +1,16 @@
 package actionCmd
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/bwebb-hx/hxutil/internal/action"
+	"github.com/bwebb-hx/hxutil/internal/config"
+	"github.com/bwebb-hx/hxutil/internal/pool"
+	"github.com/bwebb-hx/hxutil/internal/repoconfig"
+	"github.com/bwebb-hx/hxutil/internal/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -12,11 +22,107 @@ var Cmd = &cobra.Command{
 
 Commands:
 
-- diff: check for differences in the action scripts for a project between local and remote.`,
+- diff: check for differences in the action scripts for a project between local and remote.
+- pull: download the action scripts for a project from remote into a local directory.
+- push: upload local changes to the action scripts for a project to remote.
+
+If the directory (or one of its parents) has a .hxutil.yaml file (see "hxutil init"), the project, user, environment
+and script layout in it are used, so you aren't prompted for them. Flags still take priority.
+
+Locating scripts:
+
+By default, scripts can be anywhere under the directory, named after the display ID of the action or function:
+<action display ID>.<pre|post>.js (or "-pre.js", "_pre.js"), and <function display ID>.js. TypeScript (.ts) sources are also found.
+If more than one file matches a script, it is reported as ambiguous instead of picking one.
+
+The directory is only read once. node_modules/ and .git/ are skipped, along with anything in .gitignore files
+(from the directory of .hxutil.yaml down, if there is one) and the "ignore" list of .hxutil.yaml. The ignore list
+is applied last, so "!node_modules/" includes node_modules again even if a .gitignore file lists it.
+
+The mapping can be set in .hxutil.yaml, with templates (glob patterns, with placeholders) and/or a manifest of explicit paths:
+
+mapping:
+  actions: "{datastore}/{action}.{type}.{ext}"
+  functions: "functions/{function}.{ext}"
+  manifest: scripts.manifest.yaml
+
+Placeholders: {datastore}, {action}, {type} (pre or post), {function}, {ext} (js or ts) and {sep} (".", "_" or "-").
+The manifest maps action and function IDs (or display IDs) to paths, relative to the scripts directory:
+
+actions:
+  <action ID>:
+    pre: orders/validate.pre.js
+    post: orders/validate.post.ts
+functions:
+  <function ID>: functions/notify.js`,
 	// Uncomment the following line if the bare command
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
 }
 
+var concurrency int
+
 func init() {
+	Cmd.PersistentFlags().IntVar(&concurrency, "concurrency", pool.DEFAULT_CONCURRENCY, "number of scripts to download at the same time.")
+}
+
+// applyConcurrency checks and applies --concurrency
+func applyConcurrency() error {
+	if concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+	pool.SetConcurrency(concurrency)
+	return nil
+}
+
+// resolveLocalTree gives the local tree of scripts under --dir. Messages are written to out.
+// If a repo config (.hxutil.yaml) is found in --dir or one of its parents, its project, user, environment and layout are used,
+// unless they are overridden by flags.
+func resolveLocalTree(cmd *cobra.Command, out io.Writer) (action.LocalTree, error) {
+	absPath, err := filepath.Abs(dir)
+	if err != nil {
+		return action.LocalTree{}, fmt.Errorf("error resolving path: %w", err)
+	}
+
+	rc, err := repoconfig.Find(absPath)
+	if err != nil {
+		return action.LocalTree{}, fmt.Errorf("failed to load %s: %w", repoconfig.FILE_NAME, err)
+	}
+	if rc == nil {
+		return action.NewLocalTree(absPath), nil
+	}
+	utils.HintTo(out, "(using repo config: "+rc.Path()+")")
+
+	if projectID == "" {
+		projectID = rc.Project
+	}
+	if userEmail == "" {
+		userEmail = rc.User
+	}
+	// --env and HXUTIL_ENV take priority over the repo config
+	if envFlag := cmd.Flag("env"); rc.Env != "" && (envFlag == nil || !envFlag.Changed) && os.Getenv(config.ENV_VAR) == "" {
+		if err := config.UseEnvironment(rc.Env); err != nil {
+			return action.LocalTree{}, fmt.Errorf("%s: %w", rc.Path(), err)
+		}
+	}
+
+	tree := action.NewLocalTree(rc.ScriptsPath())
+	if rc.FunctionsDir != "" {
+		tree.FunctionsDir = rc.FunctionsDir
+	}
+	tree.Ignore.Add(rc.Ignore...)
+	// .gitignore files of the whole repo apply, not just those under scripts_dir
+	tree.GitignoreRoot = rc.Root()
+	tree.ActionTemplate = rc.Mapping.Actions
+	tree.FunctionTemplate = rc.Mapping.Functions
+	if manifestPath := rc.ManifestPath(); manifestPath != "" {
+		tree.Manifest, err = action.LoadManifest(manifestPath)
+		if err != nil {
+			return action.LocalTree{}, fmt.Errorf("failed to load manifest: %w", err)
+		}
+	}
+	if err := tree.Validate(); err != nil {
+		return action.LocalTree{}, fmt.Errorf("%s: %w", rc.Path(), err)
+	}
+	return tree, nil
 }
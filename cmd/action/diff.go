@@ -1,14 +1,25 @@
 package actionCmd
 
 import (
+	"errors"
 	"fmt"
-	"path/filepath"
+	"os"
+	"strings"
 
 	"github.com/bwebb-hx/hxutil/internal/action"
+	"github.com/bwebb-hx/hxutil/internal/report"
+	"github.com/bwebb-hx/hxutil/internal/utils"
 	"github.com/spf13/cobra"
 )
 
-var dir string
+var (
+	dir           string
+	projectID     string
+	userEmail     string
+	noInteractive bool
+	format        string
+	output        string
+)
 
 // diffCmd represents the diff command
 var diffCmd = &cobra.Command{
@@ -18,23 +29,67 @@ var diffCmd = &cobra.Command{
 This command is useful for ensuring that ActionScripts that are saved in Hexabase are properly tracked in your version control.
 It is expected that ActionScripts are saved in your project using the display ID of the action, suffixed with either "pre" or "post" depending on the script type.
 This command will recursively search all directories under the directory it is called in.
+If a .hxutil.yaml repo config is found, its project, user and environment are used, and its ignore patterns are respected.
+
+The command exits with a non-zero status if any differences or missing local scripts are found, so it can be used to gate merges in CI.
+TypeScript (.ts) sources aren't compared, since Hexabase only has their compiled output; they are reported as skipped, and don't
+count as differences.
 
 Suggestions to developers, to make this tool work well for you:
-- all actions that have actionscripts should have unique display IDs, to ensure the correct code is diffed.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		projectPath := dir
+- all actions that have actionscripts should have unique display IDs, to ensure the correct code is diffed.
+
+Usage Examples:
+
+# run in a CI pipeline, without any prompts
+hxutil action diff --project my-project --user ci@company.com --no-interactive
+
+# save a JUnit report as a CI artifact
+hxutil action diff --no-interactive --format junit --output action-diff.xml`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !report.ValidFormat(format) {
+			return fmt.Errorf("unknown format: %s (supported: %s)", format, strings.Join(report.Formats, ", "))
+		}
+		out := utils.Stdout()
+		if format != report.FormatText && output == "" {
+			// the report goes to stdout, so everything else goes to stderr
+			out = utils.Stderr()
+		}
 
-		absPath, err := filepath.Abs(projectPath)
+		tree, err := resolveLocalTree(cmd, out)
 		if err != nil {
-			fmt.Printf("Error resolving path: %d\n", err)
-			return
+			return err
 		}
 
-		action.DiffActionScripts(absPath)
+		if err := applyConcurrency(); err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+
+		action.INTERACTIVE_MODE = !noInteractive
+		rep, err := action.DiffActionScripts(ctx, out, tree, projectID, userEmail)
+		if err != nil {
+			return err
+		}
+		if err := rep.Output(format, output, os.Stdout); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		if ctx.Err() != nil {
+			return errors.New("interrupted")
+		}
+		if rep.DriftFound() {
+			return errors.New("differences or missing local scripts found")
+		}
+		return nil
 	},
 }
 
 func init() {
 	diffCmd.Flags().StringVarP(&dir, "dir", "d", ".", "path to a project directory to diff. defaults to the current directory.")
+	diffCmd.Flags().StringVarP(&projectID, "project", "p", "", "project ID or display ID to diff against. if not set, you are prompted to select one.")
+	diffCmd.Flags().StringVarP(&userEmail, "user", "u", "", "email of a user registered in config to login with. if not set, you are prompted to select one.")
+	diffCmd.Flags().BoolVar(&noInteractive, "no-interactive", false, "never prompt for input or pause between diffs. useful for CI pipelines.")
+	diffCmd.Flags().StringVarP(&format, "format", "f", report.FormatText, "format of the diff report: "+strings.Join(report.Formats, ", ")+". non-text reports are written to stdout, unless --output is set.")
+	diffCmd.Flags().StringVarP(&output, "output", "o", "", "path to a file to write the diff report to.")
 	Cmd.AddCommand(diffCmd)
 }
@@ -0,0 +1,58 @@
+package actionCmd
+
+import (
+	"errors"
+
+	"github.com/bwebb-hx/hxutil/internal/action"
+	"github.com/bwebb-hx/hxutil/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var force bool
+
+// pullCmd represents the pull command
+var pullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Download all ActionScripts and function scripts for a project from Hexabase into a local directory.",
+	Long: `Download all ActionScripts and function scripts for a project from Hexabase into a local directory.
+This command is useful for bootstrapping a repository from an existing project, or for bringing local scripts up to date.
+
+Scripts are saved using the following layout, which "hxutil action diff" recognizes:
+
+<datastore display ID>/<action display ID>.pre.js
+<datastore display ID>/<action display ID>.post.js
+functions/<function display ID>.js
+
+If a .hxutil.yaml repo config is found, scripts are saved under its scripts_dir, and function scripts under its functions_dir.
+If it has a mapping (see "hxutil action --help"), scripts are saved to the paths in its manifest, or given by its templates
+(as .js files), as long as the template doesn't have wildcards.
+Local files that have been modified since they were last pulled will not be overwritten, unless the --force flag is set.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tree, err := resolveLocalTree(cmd, utils.Stdout())
+		if err != nil {
+			return err
+		}
+
+		if err := applyConcurrency(); err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+
+		if err := action.PullActionScripts(ctx, tree, projectID, userEmail, force); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return errors.New("interrupted")
+		}
+		return nil
+	},
+}
+
+func init() {
+	pullCmd.Flags().StringVarP(&dir, "dir", "d", ".", "path to the directory to save scripts in. defaults to the current directory.")
+	pullCmd.Flags().BoolVarP(&force, "force", "f", false, "overwrite local files, even if they have been modified since they were last pulled.")
+	pullCmd.Flags().StringVarP(&projectID, "project", "p", "", "project ID or display ID to use. if not set, you are prompted to select one.")
+	pullCmd.Flags().StringVarP(&userEmail, "user", "u", "", "email of a user registered in config to login with. if not set, you are prompted to select one.")
+	Cmd.AddCommand(pullCmd)
+}
@@ -0,0 +1,63 @@
+package actionCmd
+
+import (
+	"errors"
+
+	"github.com/bwebb-hx/hxutil/internal/action"
+	"github.com/bwebb-hx/hxutil/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dryRun bool
+	yes    bool
+)
+
+// pushCmd represents the push command
+var pushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Upload local ActionScripts and function scripts that differ from the versions saved in Hexabase.",
+	Long: `Upload local ActionScripts and function scripts that differ from the versions saved in Hexabase.
+Local scripts are found the same way as "hxutil action diff" finds them, so the same naming rules apply.
+Only .js files are pushed; TypeScript sources are skipped, since they need to be compiled first.
+
+The diff for each changed script is shown, and you are asked to confirm before anything is uploaded.
+Only scripts with differences are uploaded. Once finished, a summary of what was pushed, skipped or failed is shown.
+
+Usage Examples:
+
+# see what would be pushed, without uploading anything
+hxutil action push --dry-run
+
+# push without asking for confirmation
+hxutil action push -y`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tree, err := resolveLocalTree(cmd, utils.Stdout())
+		if err != nil {
+			return err
+		}
+
+		if err := applyConcurrency(); err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+
+		if err := action.PushActionScripts(ctx, tree, projectID, userEmail, dryRun, yes); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return errors.New("interrupted")
+		}
+		return nil
+	},
+}
+
+func init() {
+	pushCmd.Flags().StringVarP(&dir, "dir", "d", ".", "path to a project directory to push from. defaults to the current directory.")
+	pushCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would be pushed, without uploading anything.")
+	pushCmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation step.")
+	pushCmd.Flags().StringVarP(&projectID, "project", "p", "", "project ID or display ID to use. if not set, you are prompted to select one.")
+	pushCmd.Flags().StringVarP(&userEmail, "user", "u", "", "email of a user registered in config to login with. if not set, you are prompted to select one.")
+	Cmd.AddCommand(pushCmd)
+}
@@ -1,6 +1,10 @@
 package apiCmd
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/bwebb-hx/hxutil/internal/config"
+	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/spf13/cobra"
+)
 
 // Root for the action command group
 var Cmd = &cobra.Command{
@@ -10,7 +14,10 @@ var Cmd = &cobra.Command{
 
 Commands:
 
-- test: run tests to see how APIs are currently performing.`,
+- test: run tests to see how APIs are currently performing.
+- history: show trends in the results of past tests.
+- load: load test APIs, to see how they behave under load.
+- call: call an API as a one-off test, and show the response.`,
 	// Uncomment the following line if the bare command
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -18,3 +25,12 @@ Commands:
 
 func init() {
 }
+
+// newClient gives an API client for the selected environment
+func newClient() (*hexaclient.Client, error) {
+	c, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return c.NewClient(), nil
+}
@@ -1,21 +1,40 @@
 package apiCmd
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net/http"
+	"os"
 	"strings"
 
+	"github.com/bwebb-hx/hxutil/internal/config"
 	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/placeholder"
+	"github.com/bwebb-hx/hxutil/internal/utils"
 	"github.com/spf13/cobra"
 )
 
 var (
 	method   string
 	body     string
+	bodyFile string
+	headers  []string
+	query    []string
 	auth     bool
 	email    string
 	password string
+
+	retryNonIdempotent bool
+
+	// placeholders
+	callProject   string
+	callDatastore string
+	callAction    string
+	callVars      []string
 )
 
 var callCmd = &cobra.Command{
@@ -23,86 +42,266 @@ var callCmd = &cobra.Command{
 	Short: "Calls a given URI as a one-off test, and shows the response",
 	Long: `Calls a given URI as a one-off test, and shows the response.
 
-You can optionally enter variable naems in the URI, such as ":p-id" (or :project-id), :d-id (:datastore-id), etc
-which will be automatically replaced with the config variables, user, etc.
+You can optionally enter placeholders in the URI and query params, which are replaced before the call is made:
+
+  :p-id (or :project-id)     ID of the project selected with --project (defaults to the last used project)
+  :w-id (or :workspace-id)   workspace ID of the selected project (project must be registered in config)
+  :d-id (or :datastore-id)   ID of the datastore selected with --datastore, by display ID
+  :a-id (or :action-id)      ID of the action selected with --action, by display ID (requires --datastore)
+  :<name>                    user-defined variables, from the "vars" section of the config file or --var name=value
+
+Unknown placeholders are an error. Use a double colon for a colon that isn't a placeholder, such as "sort=name::asc".
+
+Datastores and actions are looked up with the API, so --auth is required to use them.
+When --auth is set without an email, the last login user of the selected project is used if there is one.
+
+Supported methods are GET, POST, PUT, PATCH and DELETE.
+
+URIs are called against the API base URL of the selected environment. Start the URI with "@console" to call
+the management console instead (as the unofficial APIs do), or give a full URL to call it as is.
 
 // do a GET request, with config variables (:d-id) applied to the URI and the auth flag set
-hxutil api call /api/v0/datastores/:d-id/actions -a
+hxutil api call /api/v0/datastores/:d-id/actions -a --project my-project --datastore customers
 
 // do a POST call with a payload, without authorization
-hxutil api call /api/v0/login -m POST -b '{ "email": "user@company.com", "password": "xyz" }'`,
-	Run: func(cmd *cobra.Command, args []string) {
+hxutil api call /api/v0/login -m POST -b '{ "email": "user@company.com", "password": "xyz" }'
+
+// do a PUT call with a payload read from a file, a query param and an extra header
+hxutil api call /api/v0/some/resource -a -m PUT --body-file payload.json -q lang=ja -H 'X-Request-Id: 123'
+
+// read the payload from stdin
+cat payload.json | hxutil api call /api/v0/some/resource -a -m PATCH --body-file -`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			cmd.PrintErrln("URI is required")
-			return
+			return errors.New("URI is required")
 		}
 		uri := args[0]
 
+		method = strings.ToUpper(method)
+		if !hexaclient.IsSupportedMethod(method) {
+			return fmt.Errorf("provided method not recognized or supported: %s", method)
+		}
+
+		c, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+		client := c.NewClient()
+		resolver, err := newResolver(c, client)
+		if err != nil {
+			return err
+		}
+
+		req := hexaclient.ApiRequest{
+			Method:     method,
+			URI:        uri,
+			AllowRetry: retryNonIdempotent,
+		}
+		req.Headers, err = parseKeyValues(headers, ":")
+		if err != nil {
+			return fmt.Errorf("invalid header: %w", err)
+		}
+		req.Query, err = parseKeyValues(query, "=")
+		if err != nil {
+			return fmt.Errorf("invalid query param: %w", err)
+		}
+		req.Body, err = readBody()
+		if err != nil {
+			return err
+		}
+		if req.Body != nil && method == hexaclient.GET {
+			fmt.Println("Warning: given body not used since this is a GET request. Use the --method flag to make a POST, PUT or PATCH request.")
+			req.Body = nil
+		}
+
 		if auth {
-			loginEmail, loginPassword := hexaclient.TestAccUser, hexaclient.TestAccPass
-			if email != "" && password != "" {
-				loginEmail = email
-				loginPassword = password
-			}
-			token := hexaclient.Login(loginEmail, loginPassword)
-			if token == "" {
-				log.Println("token from Login is empty")
+			// the project is only used for its last login user here, so one that can't be found isn't an error until a placeholder needs it
+			project, _ := resolver.Project()
+			if err := login(cmd.Context(), client, c, project); err != nil {
+				return fmt.Errorf("failed to login: %w", err)
 			}
 		}
 
-		if method == "GET" {
-			if body != "" {
-				fmt.Println("Warning: given body not used since this is a GET request. Use the --method flag to make a POST request.")
-			}
-			resp, err := hexaclient.GetApi(uri, nil)
-			if err != nil {
-				cmd.PrintErrln("Error occurred in API execution:", err)
-				return
-			}
-			formatResponse(resp)
-			return
-		} else if method == "POST" {
-			resp, err := hexaclient.PostApi(uri, []byte(body))
+		// resolve placeholders. this is done after logging in, since datastores and actions are looked up with the API.
+		req.URI, err = resolver.Resolve(cmd.Context(), req.URI)
+		if err != nil {
+			return fmt.Errorf("failed to resolve URI: %w", err)
+		}
+		for key, value := range req.Query {
+			req.Query[key], err = resolver.Resolve(cmd.Context(), value)
 			if err != nil {
-				cmd.PrintErrln("Error occurred in APIP execution:", err)
-				return
+				return fmt.Errorf("failed to resolve query param: %w", err)
 			}
-			formatResponse(resp)
-			return
 		}
-		// unsupported method
-		cmd.PrintErrln("Provided method not recognized or supported:", method)
+		if req.URI != uri {
+			fmt.Println("URI:", req.URI)
+		}
+
+		resp, err := client.Do(cmd.Context(), req)
+		if err != nil {
+			return fmt.Errorf("error occurred in API execution: %w", err)
+		}
+		fmt.Printf("Status: %v %s (%v ms)\n", resp.StatusCode, http.StatusText(resp.StatusCode), resp.Duration.Milliseconds())
+		if resp.Retries > 0 {
+			utils.Hint(fmt.Sprintf("(retried %v times)", resp.Retries))
+		}
+		formatResponse(resp.Body)
+		return nil
 	},
 }
 
 func init() {
-	callCmd.Flags().StringVarP(&method, "method", "m", "GET", "method to use when calling the API.")
-	callCmd.Flags().StringVarP(&body, "body", "b", "", "body payload to pass when calling the API. only used for POST requests.")
+	callCmd.Flags().StringVarP(&method, "method", "m", "GET", "method to use when calling the API (GET, POST, PUT, PATCH or DELETE).")
+	callCmd.Flags().StringVarP(&body, "body", "b", "", "body payload to pass when calling the API. not used for GET requests.")
+	callCmd.Flags().StringVar(&bodyFile, "body-file", "", "file to read the body payload from. use '-' to read from stdin.")
+	callCmd.Flags().StringArrayVarP(&headers, "header", "H", []string{}, "header to send, as 'Key: Value'. can be given multiple times.")
+	callCmd.Flags().StringArrayVarP(&query, "query", "q", []string{}, "query param to send, as key=value. can be given multiple times.")
 	callCmd.Flags().BoolVarP(&auth, "auth", "a", false, "if flag is set, config is used to get hexabase auth token to pass in authorization header.")
-	callCmd.Flags().StringVarP(&email, "email", "e", "", "email to use for logging in (only used when auth flag set). defaults to test user.")
+	callCmd.Flags().StringVarP(&email, "email", "e", "", "email to use for logging in (only used when auth flag set). must be a registered user, unless --password is given. defaults to test user.")
 	callCmd.Flags().StringVarP(&password, "password", "p", "", "password to use for logging in (only used when auth flag set). defaults to test user.")
+	callCmd.Flags().BoolVar(&retryNonIdempotent, "retry-non-idempotent", false, "allow the call to be retried even if its method (such as POST) isn't idempotent.")
+	callCmd.Flags().StringVar(&callProject, "project", "", "project (ID or display ID) used for placeholders. defaults to the last used project.")
+	callCmd.Flags().StringVarP(&callDatastore, "datastore", "d", "", "datastore (display ID) used for the :d-id placeholder.")
+	callCmd.Flags().StringVar(&callAction, "action", "", "action (display ID) used for the :a-id placeholder.")
+	callCmd.Flags().StringArrayVar(&callVars, "var", []string{}, "variable to use as a placeholder, as name=value. can be given multiple times.")
 
 	Cmd.AddCommand(callCmd)
 }
 
-func formatResponse(resp []byte) {
-	// check if the response is a json
-	rawString := strings.TrimSpace(string(resp))
-	if rawString[0] == '{' && rawString[len(rawString)-1] == '}' {
-		var jsonData map[string]interface{}
-		if err := json.Unmarshal(resp, &jsonData); err != nil {
-			log.Fatal("failed to unmarshal json:", err)
+// newResolver sets up placeholder resolution from the config and flags. Datastores and actions are looked up with the client.
+func newResolver(c *config.Config, client *hexaclient.Client) (*placeholder.Resolver, error) {
+	vars := make(map[string]string)
+	for name, value := range c.Vars {
+		vars[name] = value
+	}
+	flagVars, err := parseKeyValues(callVars, "=")
+	if err != nil {
+		return nil, fmt.Errorf("invalid variable: %w", err)
+	}
+	for name, value := range flagVars {
+		vars[name] = value
+	}
+
+	resolver := &placeholder.Resolver{
+		Client:    client,
+		Datastore: callDatastore,
+		Action:    callAction,
+		Vars:      vars,
+		LoadProject: func() (*config.Project, error) {
+			return selectedProject(c)
+		},
+	}
+	for _, project := range c.Projects {
+		resolver.RegisteredProjects = append(resolver.RegisteredProjects, project.DisplayID)
+	}
+	return resolver, nil
+}
+
+// selectedProject gives the project selected with --project, or else the last used project. nil means there isn't one.
+// a call is a one-off, so the selection isn't saved to config.
+func selectedProject(c *config.Config) (*config.Project, error) {
+	if callProject != "" {
+		if project := c.GetProject(callProject); project != nil {
+			return project, nil
+		}
+		// projects don't need to be registered to be used
+		return &config.Project{P_ID: callProject}, nil
+	}
+	if c.LastUsedProject == "" {
+		return nil, nil
+	}
+	project := c.GetProject(c.LastUsedProject)
+	if project == nil {
+		return nil, fmt.Errorf("last used project %s is not registered in config; select one with --project", c.LastUsedProject)
+	}
+	return project, nil
+}
+
+// login determines who to login as for --auth, and logs the client in.
+// flags take priority, then the last login user of the project, then the test account.
+// An email given with --email must be registered, unless --password is given too.
+func login(ctx context.Context, client *hexaclient.Client, c *config.Config, project *config.Project) error {
+	if email != "" && password != "" {
+		_, err := client.LoginFresh(ctx, email, password)
+		return err
+	}
+	loginEmail := email
+	if loginEmail == "" && project != nil {
+		loginEmail = project.LastLoginUser
+	}
+	if loginEmail != "" {
+		if user := c.GetUser(loginEmail); user != nil {
+			return user.Login(ctx, client)
+		}
+		if email != "" {
+			return fmt.Errorf("user not found in config: %s (register it with 'hxutil config user add', or give --password)", email)
+		}
+		utils.Warn("user not found in config: "+loginEmail, "falling back to the test account")
+	}
+	_, err := client.LoginFresh(ctx, hexaclient.TestAccUser, hexaclient.TestAccPass)
+	return err
+}
+
+// parseKeyValues splits each "key<sep>value" pair into a map
+func parseKeyValues(pairs []string, sep string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, sep)
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("expected key%svalue, got %q", sep, pair)
 		}
-		// great, we've successfully unmarshalled the data. let's remarshal it with some indentation now to make it readable
-		indentJsonBytes, err := json.MarshalIndent(jsonData, "", "  ")
+		values[key] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
+// readBody gives the body payload from either the --body or --body-file flag. nil means no body was given.
+func readBody() ([]byte, error) {
+	if body != "" && bodyFile != "" {
+		return nil, fmt.Errorf("only one of --body and --body-file can be used")
+	}
+	if body != "" {
+		return []byte(body), nil
+	}
+	if bodyFile == "" {
+		return nil, nil
+	}
+	if bodyFile == "-" {
+		bodyBytes, err := io.ReadAll(os.Stdin)
 		if err != nil {
-			log.Fatal("failed to remarshal json:", err)
+			return nil, fmt.Errorf("failed to read body from stdin: %w", err)
 		}
-		fmt.Println("Response (formatted JSON):")
-		fmt.Println(string(indentJsonBytes))
+		return bodyBytes, nil
+	}
+	bodyBytes, err := os.ReadFile(bodyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body file: %w", err)
+	}
+	return bodyBytes, nil
+}
+
+func formatResponse(resp []byte) {
+	rawString := strings.TrimSpace(string(resp))
+	if rawString == "" {
+		fmt.Println("Response: (empty)")
 		return
 	}
-	// response doesn't appear to be a (single) json. so just show the raw string data
+	// check if the response is a json
+	if json.Valid([]byte(rawString)) && (rawString[0] == '{' || rawString[0] == '[') {
+		// let's re-indent it to make it readable
+		var indentJson bytes.Buffer
+		if err := json.Indent(&indentJson, []byte(rawString), "", "  "); err == nil {
+			fmt.Println("Response (formatted JSON):")
+			fmt.Println(indentJson.String())
+			return
+		}
+	}
+	// response doesn't appear to be a json. so just show the raw string data
 	fmt.Println("Response (raw string):")
 	fmt.Println(rawString)
 }
@@ -0,0 +1,142 @@
+package apiCmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bwebb-hx/hxutil/internal/config"
+	"github.com/bwebb-hx/hxutil/internal/history"
+	"github.com/bwebb-hx/hxutil/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historySuite    string
+	historyLimit    int
+	historyEndpoint string
+
+	compareBaseline     bool
+	baselineRuns        int
+	regressionThreshold float64
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show trends in the results of past API tests",
+	Long: `Show trends in the latency and pass rate of each endpoint, over past runs of "hxutil api test".
+Only runs against the selected environment (see --env) are shown, so latencies of different environments aren't mixed.
+
+Usage Examples:
+
+# show the last 10 runs of the default suite
+hxutil api history
+
+# only show endpoints containing "datastores", and flag regressions in the latest run
+hxutil api history --endpoint datastores --compare-baseline`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runs, err := history.Load(historySuite)
+		if err != nil {
+			return fmt.Errorf("failed to load history: %w", err)
+		}
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		runs = history.InEnvironmentOf(runs, history.Run{BaseURL: client.BaseURL})
+		if len(runs) == 0 {
+			utils.Hint(fmt.Sprintf("(No history found for suite %s in the %s environment)", historySuite, config.SelectedEnvironment()))
+			return nil
+		}
+		if historyLimit > 0 && len(runs) > historyLimit {
+			runs = runs[len(runs)-historyLimit:]
+		}
+
+		printHistory(runs)
+
+		if compareBaseline {
+			latest := runs[len(runs)-1]
+			if checkRegressions(latest, runs[:len(runs)-1]) {
+				return errors.New("regressions found")
+			}
+		}
+		return nil
+	},
+}
+
+func printHistory(runs []history.Run) {
+	// list endpoints in the order they appear, newest runs first
+	endpoints := make([]string, 0)
+	seen := make(map[string]bool)
+	for i := len(runs) - 1; i >= 0; i-- {
+		for _, result := range runs[i].Results {
+			if historyEndpoint != "" && !strings.Contains(result.Endpoint, historyEndpoint) {
+				continue
+			}
+			if !seen[result.Endpoint] {
+				seen[result.Endpoint] = true
+				endpoints = append(endpoints, result.Endpoint)
+			}
+		}
+	}
+
+	for _, endpoint := range endpoints {
+		means := make([]int64, 0)
+		passRates := make([]int64, 0)
+		fmt.Println()
+		utils.ColorInfo.Println(endpoint)
+		fmt.Printf("  %-16s %5s %6s %6s %6s %6s %5s\n", "TIME", "PASS", "MEAN", "P50", "P90", "P99", "RETRY")
+		for _, run := range runs {
+			for _, result := range run.Results {
+				if result.Endpoint != endpoint {
+					continue
+				}
+				passRate := result.PassRate()
+				passColor := utils.ColorSuccess
+				if passRate < 1 {
+					passColor = utils.ColorError
+				}
+				fmt.Printf("  %-16s %s %6d %6d %6d %6d %5d\n", run.Time.Local().Format("2006-01-02 15:04"), passColor.Sprintf("%4.0f%%", passRate*100), result.MeanMs, result.P50Ms, result.P90Ms, result.P99Ms, result.Retries)
+				means = append(means, result.MeanMs)
+				passRates = append(passRates, int64(passRate*100))
+			}
+		}
+		utils.ColorHint.Printf("  mean trend: %s  pass rate trend: %s\n", history.Sparkline(means), history.Sparkline(passRates))
+	}
+}
+
+// checkRegressions compares a run against the runs before it in the same environment, and shows any regressions.
+// Returns true if regressions are found.
+func checkRegressions(current history.Run, previous []history.Run) bool {
+	previous = history.InEnvironmentOf(previous, current)
+	if baselineRuns > 0 && len(previous) > baselineRuns {
+		previous = previous[len(previous)-baselineRuns:]
+	}
+	if len(previous) == 0 {
+		utils.Hint("(No previous runs in this environment to compare against)")
+		return false
+	}
+
+	regressions := history.CompareToBaseline(current, previous, regressionThreshold)
+	fmt.Printf("\n== BASELINE COMPARISON (last %v runs, threshold %.0f%%) ==\n", len(previous), regressionThreshold)
+	if len(regressions) == 0 {
+		utils.ColorSuccess.Println("No regressions found!")
+		return false
+	}
+	for _, regression := range regressions {
+		utils.ColorError.Println("REGRESSION:", regression)
+	}
+	return true
+}
+
+func init() {
+	historyCmd.Flags().StringVarP(&historySuite, "suite", "s", "default", "name of the test suite to show history for.")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 10, "number of most recent runs to show. 0 shows all runs.")
+	historyCmd.Flags().StringVarP(&historyEndpoint, "endpoint", "e", "", "only show endpoints containing this text.")
+	historyCmd.Flags().BoolVar(&compareBaseline, "compare-baseline", false, "flag regressions in the latest run, compared to the runs before it.")
+	historyCmd.Flags().IntVar(&baselineRuns, "baseline-runs", 5, "number of previous runs to average as the baseline.")
+	historyCmd.Flags().Float64Var(&regressionThreshold, "regression-threshold", 20, "percentage a latency can increase by before it is flagged as a regression.")
+
+	Cmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,98 @@
+package apiCmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	loadTests       []string
+	loadConcurrency int
+	loadRPS         float64
+	loadDuration    time.Duration
+	loadRampUp      time.Duration
+)
+
+var loadCmd = &cobra.Command{
+	Use:   "load",
+	Short: "Load test Hexabase APIs",
+	Long: `Load test Hexabase APIs, by calling them concurrently at a target rate for a set duration.
+
+The APIs to call, and the assertions to check each response with, come from an API test suite (see "hxutil api test --help").
+A suite must be given with --suite, or tests of the built-in suite picked with --test. Tests that aren't safe to repeat (POST and
+PATCH requests, and auth endpoints such as Login and ForgotPassword) are skipped, unless they are named with --test.
+Once finished, the throughput, latency histogram, and breakdown of errors by status code are shown for each endpoint.
+Failed calls aren't retried, unless --retries is given.
+
+Usage Examples:
+
+# call the GetDatastores test of a suite with 20 workers, at up to 50 requests per second, for 1 minute
+hxutil api load --suite tests.yaml --test GetDatastores -c 20 --rps 50 --duration 1m
+
+# ramp up to full load over the first 30 seconds, with no rate limit
+hxutil api load --suite tests.yaml -c 50 --rps 0 --duration 2m --ramp-up 30s`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if loadConcurrency < 1 {
+			return errors.New("concurrency must be at least 1")
+		}
+		if loadDuration <= 0 {
+			return errors.New("duration must be positive")
+		}
+
+		if suitePath == "" && len(loadTests) == 0 {
+			return errors.New("choose what to load test with --suite, or with --test for tests of the built-in suite")
+		}
+
+		var suite *hexaclient.ApiTestSuite
+		var err error
+		if suitePath == "" {
+			suite, err = hexaclient.DefaultApiTestSuite()
+		} else {
+			suite, err = hexaclient.LoadApiTestSuite(suitePath)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load test suite: %w", err)
+		}
+		if err := suite.Filter(loadTests); err != nil {
+			return err
+		}
+		// tests named with --test are called as asked, even if they aren't safe to repeat
+		if len(loadTests) == 0 {
+			if skipped := suite.FilterLoadSafe(); len(skipped) > 0 {
+				utils.Hint(fmt.Sprintf("(skipping tests that aren't safe to repeat: %s. name them with --test to call them anyway.)", strings.Join(skipped, ", ")))
+			}
+		}
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		// retries would hide errors and skew the rate, so they are only done if asked for
+		if !cmd.Flags().Changed("retries") {
+			client.RetryPolicy.MaxRetries = 0
+		}
+
+		return hexaclient.RunLoadTest(cmd.Context(), client, suite, hexaclient.LoadTestOptions{
+			Concurrency: loadConcurrency,
+			RPS:         loadRPS,
+			Duration:    loadDuration,
+			RampUp:      loadRampUp,
+		})
+	},
+}
+
+func init() {
+	loadCmd.Flags().StringVarP(&suitePath, "suite", "s", "", "path to a YAML or JSON file of API tests to call. defaults to the built-in suite, if --test is set.")
+	loadCmd.Flags().StringSliceVarP(&loadTests, "test", "t", nil, "name of a test in the suite to call. can be repeated. defaults to all tests in the suite that are safe to repeat.")
+	loadCmd.Flags().IntVarP(&loadConcurrency, "concurrency", "c", 10, "number of workers calling APIs at the same time.")
+	loadCmd.Flags().Float64Var(&loadRPS, "rps", 10, "target requests per second, across all workers. 0 means unlimited.")
+	loadCmd.Flags().DurationVarP(&loadDuration, "duration", "d", 30*time.Second, "how long to run the load test for.")
+	loadCmd.Flags().DurationVar(&loadRampUp, "ramp-up", 0, "time taken to ramp up to full concurrency and rate.")
+	Cmd.AddCommand(loadCmd)
+}
@@ -1,22 +1,107 @@
 package apiCmd
 
 import (
+	"errors"
+	"fmt"
+
+	"github.com/bwebb-hx/hxutil/internal/config"
 	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/history"
 	"github.com/spf13/cobra"
 )
 
+var (
+	suitePath string
+	noHistory bool
+)
+
 var testCmd = &cobra.Command{
 	Use:   "test",
 	Short: "Test the Hexabase APIs",
 	Long: `Run tests on the Hexabase APIs to see how they are currently performing.
 
+By default, a built-in suite of tests is run. You can also describe your own tests in a YAML or JSON suite file:
+
+name: my-suite
+repeat: 3                    # times to run each test (can be overridden per test)
+vars:                        # variables usable as {{name}} in test cases ({{base_url}} and {{console_url}} are always available)
+  p_id: 674716ff253630d46156a153
+login:                       # credentials used for tests that require auth
+  email: user@company.com
+  password: xyz
+tests:
+  - name: GetDatastores
+    endpoint: GetDatastores  # a registered API, or set uri and method instead
+    path_params: ["{{p_id}}"]
+    query: {}
+    payload: {}
+    auth: true               # defaults to whether the endpoint requires a token
+    assert:
+      status: 200
+      json_exists: ["0.datastore_id"]
+      json_equals: {"0.deleted": false}
+      body_contains: ["datastore_id"]
+      max_latency_ms: 1000
+
+Results are recorded in the config directory, along with the environment they are from, and can be viewed with "hxutil api history".
+Baselines only use previous runs against the same environment.
+
 Usage:
-hxutil api test`,
-	Run: func(cmd *cobra.Command, args []string) {
-		hexaclient.RunStatusCheck()
+hxutil api test
+hxutil api test --suite tests.yaml
+
+# fail if an endpoint is more than 30% slower than the average of the last 5 runs
+hxutil api test --compare-baseline --regression-threshold 30`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var suite *hexaclient.ApiTestSuite
+		var err error
+		if suitePath == "" {
+			suite, err = hexaclient.DefaultApiTestSuite()
+		} else {
+			suite, err = hexaclient.LoadApiTestSuite(suitePath)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load test suite: %w", err)
+		}
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		results, err := hexaclient.RunStatusCheck(cmd.Context(), client, suite)
+		if err != nil {
+			// an incomplete run isn't recorded, since it would throw off the baseline
+			return err
+		}
+		run := history.NewRun(suite.Name, config.SelectedEnvironment(), client.BaseURL, results)
+
+		regressionFound := false
+		if compareBaseline {
+			previous, err := history.Load(suite.Name)
+			if err != nil {
+				return fmt.Errorf("failed to load history: %w", err)
+			}
+			regressionFound = checkRegressions(run, previous)
+		}
+
+		if !noHistory {
+			if err := history.Append(run); err != nil {
+				return fmt.Errorf("failed to record results: %w", err)
+			}
+		}
+
+		if regressionFound {
+			return errors.New("regressions found")
+		}
+		return nil
 	},
 }
 
 func init() {
+	testCmd.Flags().StringVarP(&suitePath, "suite", "s", "", "path to a YAML or JSON file of API tests to run. defaults to the built-in suite.")
+	testCmd.Flags().BoolVar(&noHistory, "no-history", false, "don't record the results of this run.")
+	testCmd.Flags().BoolVar(&compareBaseline, "compare-baseline", false, "flag regressions compared to previous runs of the same suite.")
+	testCmd.Flags().IntVar(&baselineRuns, "baseline-runs", 5, "number of previous runs to average as the baseline.")
+	testCmd.Flags().Float64Var(&regressionThreshold, "regression-threshold", 20, "percentage a latency can increase by before it is flagged as a regression.")
 	Cmd.AddCommand(testCmd)
 }
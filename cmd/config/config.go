@@ -1,25 +1,69 @@
 package configCmd
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/bwebb-hx/hxutil/internal/config"
+	"github.com/bwebb-hx/hxutil/internal/console"
 	"github.com/bwebb-hx/hxutil/internal/utils"
 	"github.com/spf13/cobra"
 )
 
+var jsonOutput bool
+
 // Root for the action command group
 var Cmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage hxutil configuration",
-	Long:  `Manage hxutil configuration`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Manage hxutil configuration.
+
+Commands:
+  project     add, remove and list registered projects
+  user        add, remove and list registered users
+  use-project set the project used by default
+  get         show config values
+  set         change a config value
+
+All commands take their values from args and flags, so they can be used in scripts.
+Use --json for machine-readable output.
+
+Run without a command to open the config console, a keyboard driven UI for browsing and managing
+projects, users and environments.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := config.ConfigFilePath()
+		if err != nil {
+			return fmt.Errorf("failed to find config: %w", err)
+		}
+		if !console.IsTerminal() {
+			// the console can't be used, so just point to the other ways of managing config
+			utils.Info("config path: "+path, "Edit this file, or use the config commands (see --help), to make changes to configuration")
+			return nil
+		}
 		// starts an interface to let users manage config
-		fmt.Println("HXUTIL Config Console: Coming soon!")
-		path := config.ConfigFilePath()
-		utils.Info("config path: "+path, "Edit this file to make changes to configuration")
+		if err := console.Run(cmd.Context()); err != nil {
+			utils.Info("config path: "+path, "Edit this file to make changes to configuration")
+			return fmt.Errorf("config console failed: %w", err)
+		}
+		return nil
 	},
 }
 
 func init() {
+	Cmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "output as JSON.")
+}
+
+// loadConfig is GetConfig, for RunE
+func loadConfig() (*config.Config, error) {
+	return config.GetConfig()
+}
+
+func printJSON(v any) error {
+	jsonBytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonBytes))
+	return nil
 }
@@ -0,0 +1,56 @@
+package configCmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwebb-hx/hxutil/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Show config values",
+	Long: `Show the value of a config key, or all config values if no key is given.
+
+Keys: ` + strings.Join(config.ConfigKeys, ", ") + `, and vars.<name> for user-defined variables.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 1 {
+			value, err := c.GetValue(args[0])
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(value)
+			}
+			fmt.Println(value)
+			return nil
+		}
+
+		values := c.Values()
+		if jsonOutput {
+			return printJSON(values)
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("%s = %s\n", key, values[key])
+		}
+		return nil
+	},
+}
+
+func init() {
+	Cmd.AddCommand(getCmd)
+}
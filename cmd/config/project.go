@@ -0,0 +1,130 @@
+package configCmd
+
+import (
+	"fmt"
+
+	"github.com/bwebb-hx/hxutil/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var projectUser string
+
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Manage registered projects",
+	Long: `Manage registered projects.
+
+Usage Examples:
+
+# register a project, logging in as a registered user to look up its details
+hxutil config project add <p_id> --user someUser@hexabase.com
+
+# list registered projects
+hxutil config project list --json
+
+# remove a project, by ID or display ID
+hxutil config project remove my-project`,
+}
+
+var projectAddCmd = &cobra.Command{
+	Use:          "add <p_id>",
+	Short:        "Register a project",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		client := c.NewClient()
+
+		if projectUser != "" {
+			user := c.GetUser(projectUser)
+			if user == nil {
+				return fmt.Errorf("user not registered: %s", projectUser)
+			}
+			if err := user.Login(cmd.Context(), client); err != nil {
+				return err
+			}
+		} else if err := c.SelectUserAndLogin(cmd.Context(), client, ""); err != nil {
+			return err
+		}
+
+		project, err := c.RegisterProject(cmd.Context(), client, args[0])
+		if err != nil {
+			return err
+		}
+		if projectUser != "" {
+			if err := c.SetProjectLastUser(project.P_ID, projectUser); err != nil {
+				return err
+			}
+		}
+
+		if jsonOutput {
+			return printJSON(project)
+		}
+		utils.ColorSuccess.Println("Registered project:", project)
+		return nil
+	},
+}
+
+var projectRemoveCmd = &cobra.Command{
+	Use:          "remove <p_id or display ID>",
+	Aliases:      []string{"rm"},
+	Short:        "Remove a registered project",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		project, err := c.RemoveProject(args[0])
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(project)
+		}
+		fmt.Println("Removed project:", project)
+		return nil
+	},
+}
+
+var projectListCmd = &cobra.Command{
+	Use:          "list",
+	Aliases:      []string{"ls"},
+	Short:        "List registered projects",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(c.Projects)
+		}
+		if len(c.Projects) == 0 {
+			utils.Hint("(no registered projects)")
+			return nil
+		}
+		for _, project := range c.Projects {
+			marker := " "
+			if project.P_ID == c.LastUsedProject {
+				marker = "*"
+			}
+			fmt.Printf("%s %-24s %s  %s  %s\n", marker, project.DisplayID, project.P_ID, project.WorkspaceName, utils.ColorHint.Sprint(project.LastLoginUser))
+		}
+		return nil
+	},
+}
+
+func init() {
+	projectAddCmd.Flags().StringVarP(&projectUser, "user", "u", "", "registered user to login as, to look up the project. prompted for if not set.")
+
+	projectCmd.AddCommand(projectAddCmd)
+	projectCmd.AddCommand(projectRemoveCmd)
+	projectCmd.AddCommand(projectListCmd)
+	Cmd.AddCommand(projectCmd)
+}
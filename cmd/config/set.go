@@ -0,0 +1,47 @@
+package configCmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwebb-hx/hxutil/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var setCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a config value",
+	Long: `Change the value of a config key. An empty value unsets it.
+
+Keys: ` + strings.Join(config.ConfigKeys, ", ") + `, and vars.<name> for user-defined variables.
+
+Usage Examples:
+
+# set the default project (which must be registered)
+hxutil config set last_used_project my-project
+
+# set a variable, usable as :app_id in "api call"
+hxutil config set vars.app_id 674716ff253630d46156a153`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if err := c.SetValue(args[0], args[1]); err != nil {
+			return err
+		}
+		// values may be normalized, such as a project display ID being stored as its ID
+		value, _ := c.GetValue(args[0])
+		if jsonOutput {
+			return printJSON(map[string]string{args[0]: value})
+		}
+		fmt.Printf("%s = %s\n", args[0], value)
+		return nil
+	},
+}
+
+func init() {
+	Cmd.AddCommand(setCmd)
+}
@@ -0,0 +1,36 @@
+package configCmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var useProjectCmd = &cobra.Command{
+	Use:   "use-project <p_id or display ID>",
+	Short: "Set the project used by default",
+	Long: `Set the project used by default, such as for placeholders in "api call".
+
+The project must be registered first, with "hxutil config project add".`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		project, err := c.UseProject(args[0])
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(project)
+		}
+		fmt.Println("Using project:", project)
+		return nil
+	},
+}
+
+func init() {
+	Cmd.AddCommand(useProjectCmd)
+}
@@ -0,0 +1,134 @@
+package configCmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bwebb-hx/hxutil/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	userPassword      string
+	userPasswordStdin bool
+)
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage registered users",
+	Long: `Manage registered users.
+
+Passwords are stored in the encrypted secrets file, not in the config file.
+
+Usage Examples:
+
+# register a user. the password is prompted for, unless given with a flag.
+hxutil config user add someUser@hexabase.com
+
+# register a user in a script, with the password read from stdin
+echo "$PASSWORD" | hxutil config user add someUser@hexabase.com --password-stdin
+
+# remove a user from the selected environment, along with their stored password and cached token
+hxutil config user remove someUser@hexabase.com`,
+}
+
+var userAddCmd = &cobra.Command{
+	Use:          "add <email>",
+	Short:        "Register a user",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		password := userPassword
+		if userPasswordStdin {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("failed to read password from stdin: %w", err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		} else if password == "" {
+			password, err = utils.GetSecretInput("Password")
+			if err != nil {
+				return err
+			}
+		}
+
+		user, err := c.AddUser(cmd.Context(), c.NewClient(), args[0], password)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(user)
+		}
+		utils.ColorSuccess.Println("Registered user:", user.Email)
+		return nil
+	},
+}
+
+var userRemoveCmd = &cobra.Command{
+	Use:          "remove <email>",
+	Aliases:      []string{"rm"},
+	Short:        "Remove a registered user",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		user, err := c.RemoveUser(args[0])
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(user)
+		}
+		fmt.Println("Removed user:", user.Email)
+		return nil
+	},
+}
+
+var userListCmd = &cobra.Command{
+	Use:          "list",
+	Aliases:      []string{"ls"},
+	Short:        "List registered users",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(c.Users)
+		}
+		if len(c.Users) == 0 {
+			utils.Hint("(no registered users)")
+			return nil
+		}
+		for _, user := range c.Users {
+			marker := " "
+			if user.Email == c.LastLoginUser {
+				marker = "*"
+			}
+			fmt.Println(marker, user.Email)
+		}
+		return nil
+	},
+}
+
+func init() {
+	userAddCmd.Flags().StringVarP(&userPassword, "password", "p", "", "password of the user. prompted for if not set.")
+	userAddCmd.Flags().BoolVar(&userPasswordStdin, "password-stdin", false, "read the password from stdin.")
+
+	userCmd.AddCommand(userAddCmd)
+	userCmd.AddCommand(userRemoveCmd)
+	userCmd.AddCommand(userListCmd)
+	Cmd.AddCommand(userCmd)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bwebb-hx/hxutil/internal/config"
+	"github.com/bwebb-hx/hxutil/internal/repoconfig"
+	"github.com/bwebb-hx/hxutil/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	initDir          string
+	initProject      string
+	initUser         string
+	initScriptsDir   string
+	initFunctionsDir string
+	initIgnore       []string
+	initForce        bool
+)
+
+// initCmd represents the init command
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a .hxutil.yaml file that binds a repository to a Hexabase project.",
+	Long: `Create a .hxutil.yaml file that binds a repository to a Hexabase project.
+Commands like "hxutil action diff", "pull" and "push" look for this file in the directory they are run in and all of its parents,
+and use the project, environment, user and script layout in it instead of prompting for them.
+
+You are prompted for anything that isn't given by flags. The environment is the one currently selected (with --env or $HXUTIL_ENV).
+Passwords left in the config file by older versions of hxutil are moved into the encrypted secrets file.
+
+Example file:
+
+project: 65a1b2c3d4e5f6a7b8c9d0e1
+env: staging
+user: someUser@hexabase.com
+scripts_dir: actionscripts
+functions_dir: functions
+ignore:
+  - dist/
+  - "*.test.js"
+
+Usage Examples:
+
+# create the file interactively in the current directory
+hxutil init
+
+# create the file without any prompts
+hxutil init --project 65a1b2c3d4e5f6a7b8c9d0e1 --user someUser@hexabase.com --scripts-dir actionscripts`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		absDir, err := filepath.Abs(initDir)
+		if err != nil {
+			return fmt.Errorf("error resolving path: %w", err)
+		}
+		if _, err := os.Stat(filepath.Join(absDir, repoconfig.FILE_NAME)); err == nil && !initForce {
+			return fmt.Errorf("%s already exists in %s (use --force to overwrite it)", repoconfig.FILE_NAME, absDir)
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		c, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+		if err := c.MigratePasswords(); err != nil {
+			return err
+		}
+
+		rc := repoconfig.RepoConfig{
+			Project:      initProject,
+			User:         initUser,
+			ScriptsDir:   initScriptsDir,
+			FunctionsDir: initFunctionsDir,
+			Ignore:       initIgnore,
+		}
+		if env := config.SelectedEnvironment(); env != config.DEFAULT_ENV {
+			rc.Env = env
+		}
+
+		if rc.Project == "" {
+			project, err := c.SelectProject(cmd.Context(), c.NewClient())
+			if err != nil {
+				return err
+			}
+			rc.Project = project.P_ID
+			if rc.User == "" {
+				rc.User = project.LastLoginUser
+			}
+		} else if project := c.GetProject(rc.Project); project != nil {
+			// save the project ID, since display IDs can be changed
+			rc.Project = project.P_ID
+		}
+
+		if !cmd.Flags().Changed("user") {
+			def := rc.User
+			if def == "" {
+				def = c.LastLoginUser
+			}
+			rc.User = utils.GetInputDefault("User to login with (registered in config)", def)
+		}
+		if rc.User != "" && c.GetUser(rc.User) == nil {
+			utils.Warn("user isn't registered in config: "+rc.User, "register it with \"hxutil config user add\" before using this repo config")
+		}
+
+		if !cmd.Flags().Changed("scripts-dir") {
+			rc.ScriptsDir = utils.GetInputDefault("Directory where scripts are kept (relative to "+absDir+")", ".")
+		}
+		if !cmd.Flags().Changed("functions-dir") {
+			rc.FunctionsDir = utils.GetInputDefault("Directory where function scripts are kept (relative to the scripts directory)", rc.FunctionsDir)
+		}
+		if !cmd.Flags().Changed("ignore") {
+			input := utils.GetInputDefault("Paths to ignore besides node_modules/ and .git/, comma separated (.gitignore style)", "")
+			for _, pattern := range strings.Split(input, ",") {
+				if pattern = strings.TrimSpace(pattern); pattern != "" {
+					rc.Ignore = append(rc.Ignore, pattern)
+				}
+			}
+		}
+
+		// leave out values that are the same as the defaults, to keep the file short
+		rc.ScriptsDir = filepath.ToSlash(filepath.Clean(rc.ScriptsDir))
+		if rc.ScriptsDir == "." {
+			rc.ScriptsDir = ""
+		}
+
+		path, err := rc.Save(absDir)
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %w", repoconfig.FILE_NAME, err)
+		}
+		utils.ColorSuccess.Println("Created", path)
+		return nil
+	},
+}
+
+func init() {
+	initCmd.Flags().StringVarP(&initDir, "dir", "d", ".", "directory to create the file in. defaults to the current directory.")
+	initCmd.Flags().StringVarP(&initProject, "project", "p", "", "project ID or display ID to bind to. if not set, you are prompted to select one.")
+	initCmd.Flags().StringVarP(&initUser, "user", "u", "", "email of a user registered in config to login with.")
+	initCmd.Flags().StringVar(&initScriptsDir, "scripts-dir", ".", "directory where scripts are kept, relative to --dir.")
+	initCmd.Flags().StringVar(&initFunctionsDir, "functions-dir", "functions", "directory where function scripts are kept, relative to --scripts-dir.")
+	initCmd.Flags().StringSliceVar(&initIgnore, "ignore", nil, "paths to skip when looking for scripts, in .gitignore style. can be repeated.")
+	initCmd.Flags().BoolVarP(&initForce, "force", "f", false, "overwrite an existing file.")
+	RootCmd.AddCommand(initCmd)
+}
@@ -7,7 +7,7 @@ import (
 	"errors"
 	"fmt"
 
-	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -36,9 +36,15 @@ var loginCmd = &cobra.Command{
 		fmt.Println("email:", email)
 		fmt.Println("password:", password)
 
-		token := hexaclient.Login(email, password)
-		if token == "" {
-			return errors.New("login failed; no token generated.")
+		c, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		// always do a real login, since this is used to confirm credentials
+		token, err := c.NewClient().LoginFresh(cmd.Context(), email, password)
+		if err != nil {
+			return fmt.Errorf("login failed: %w", err)
 		}
 
 		fmt.Println("Login successful! Token:")
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/bwebb-hx/hxutil/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// logoutCmd represents the logout command
+var logoutCmd = &cobra.Command{
+	Use:   "logout [email]",
+	Short: "Clear cached login tokens",
+	Long: `Clear cached login tokens, so the next command has to login again with a password.
+
+Login tokens are cached in the config directory, per user and per API base URL, and reused until they expire.
+
+Usage Examples:
+
+# clear all cached tokens
+hxutil logout
+
+# clear the cached tokens of one user
+hxutil logout "someUser@hexabase.com"`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		email := ""
+		if len(args) > 0 {
+			email = args[0]
+		}
+
+		removed, err := config.ClearTokens(email)
+		if err != nil {
+			return fmt.Errorf("failed to clear cached tokens: %w", err)
+		}
+		if removed == 0 {
+			fmt.Println("No cached tokens to clear.")
+			return nil
+		}
+		fmt.Printf("Cleared %v cached token(s).\n", removed)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(logoutCmd)
+}
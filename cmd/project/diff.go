@@ -1,16 +1,56 @@
 package projectCmd
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bwebb-hx/hxutil/internal/pool"
 	"github.com/bwebb-hx/hxutil/internal/project"
+	"github.com/bwebb-hx/hxutil/internal/report"
+	"github.com/bwebb-hx/hxutil/internal/utils"
 	"github.com/spf13/cobra"
 )
 
+var (
+	format      string
+	output      string
+	concurrency int
+	env1        string
+	env2        string
+	user1       string
+	user2       string
+	sections    []string
+	noPause     bool
+)
+
 // diffCmd represents the diff command
 var diffCmd = &cobra.Command{
 	Use:   "diff",
 	Short: "Diff two projects in Hexabase",
-	Long:  `Diff two projects in Hexabase.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Diff two projects in Hexabase.
+
+Each project is accessed on its own, so they can be in different environments (with --env1 and --env2), and be logged in to
+as different users (with --user1 and --user2). Environments default to the one selected with --env, and users default to
+the last user of each project. If either project can't be accessed, the diff stops before anything is compared.
+
+By default, the diff pauses after each section and each difference found. Use --no-pause to run it unattended, and
+--sections to only compare some of the sections. A summary of all the sections is shown at the end, and the command
+exits with an error if any differences were found.
+
+Usage Examples:
+
+# compare a production project with its staging copy, which is under a different account
+hxutil project diff <p_id 1> <p_id 2> --env2 staging --user2 staging-admin@company.com
+
+# write a markdown report, to post in a pull request
+hxutil project diff <p_id 1> <p_id 2> --format markdown --output project-diff.md
+
+# check that env variables and functions match, in CI
+hxutil project diff <p_id 1> <p_id 2> --sections env,functions --no-pause`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		pid1, pid2 := "", ""
 		if len(args) > 0 {
 			pid1 = args[0]
@@ -18,10 +58,61 @@ var diffCmd = &cobra.Command{
 		if len(args) > 1 {
 			pid2 = args[1]
 		}
-		project.Diff(pid1, pid2)
+
+		if !report.ValidFormat(format) {
+			return fmt.Errorf("unknown format: %s (supported: %s)", format, strings.Join(report.Formats, ", "))
+		}
+		out := utils.Stdout()
+		if format != report.FormatText && output == "" {
+			// the report goes to stdout, so everything else goes to stderr
+			out = utils.Stderr()
+		}
+
+		for _, section := range sections {
+			if !project.ValidSection(section) {
+				return fmt.Errorf("unknown section: %s (supported: %s)", section, strings.Join(project.Sections, ", "))
+			}
+		}
+		if concurrency < 1 {
+			return fmt.Errorf("--concurrency must be at least 1")
+		}
+		pool.SetConcurrency(concurrency)
+		ctx := cmd.Context()
+
+		p1, p2, err := project.SelectSides(ctx, out,
+			project.SideOptions{Project: pid1, Env: env1, User: user1},
+			project.SideOptions{Project: pid2, Env: env2, User: user2},
+		)
+		if err != nil {
+			return err
+		}
+		rep, diffErr := project.Diff(ctx, out, p1, p2, project.DiffOptions{Sections: sections, NoPause: noPause})
+		// the report is still written when part of the diff failed, so what was compared isn't lost
+		if err := rep.Output(format, output, os.Stdout); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		if ctx.Err() != nil {
+			return errors.New("interrupted")
+		}
+		if diffErr != nil {
+			return diffErr
+		}
+		if rep.DriftFound() {
+			return errors.New("differences found between the projects")
+		}
+		return nil
 	},
 }
 
 func init() {
+	diffCmd.Flags().StringVarP(&format, "format", "f", report.FormatText, "format of the diff report: "+strings.Join(report.Formats, ", ")+". non-text reports are written to stdout, unless --output is set.")
+	diffCmd.Flags().StringVarP(&output, "output", "o", "", "path to a file to write the diff report to.")
+	diffCmd.Flags().StringVar(&env1, "env1", "", "environment of the first project. defaults to the selected environment.")
+	diffCmd.Flags().StringVar(&env2, "env2", "", "environment of the second project. defaults to the selected environment.")
+	diffCmd.Flags().StringVar(&user1, "user1", "", "email of a registered user to login as for the first project. defaults to the last user of the project.")
+	diffCmd.Flags().StringVar(&user2, "user2", "", "email of a registered user to login as for the second project. defaults to the last user of the project.")
+	diffCmd.Flags().StringSliceVar(&sections, "sections", project.Sections, "sections to compare: "+strings.Join(project.Sections, ", ")+".")
+	diffCmd.Flags().BoolVar(&noPause, "no-pause", false, "don't wait for Enter after each section and each difference.")
+	diffCmd.Flags().IntVar(&concurrency, "concurrency", pool.DEFAULT_CONCURRENCY, "number of actionscripts to download at the same time.")
 	Cmd.AddCommand(diffCmd)
 }
@@ -1,20 +1,62 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"os"
+	"time"
 
 	actionCmd "github.com/bwebb-hx/hxutil/cmd/action"
 	apiCmd "github.com/bwebb-hx/hxutil/cmd/api"
 	configCmd "github.com/bwebb-hx/hxutil/cmd/config"
 	projectCmd "github.com/bwebb-hx/hxutil/cmd/project"
+	"github.com/bwebb-hx/hxutil/internal/config"
+	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/utils"
 	"github.com/spf13/cobra"
 )
 
+var (
+	envName string
+	retries int
+	timeout time.Duration
+)
+
 // rootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
 	Use:   "hxutil",
 	Short: "a collection of utility tools for hexabase!",
-	Long:  `A collection of utility tools for hexabase! Includes tools to test APIs, manage ActionScripts for projects, and more things to come.`,
+	Long: `A collection of utility tools for hexabase! Includes tools to test APIs, manage ActionScripts for projects, and more things to come.
+
+Environments (such as staging or dev deployments of hexabase) can be added under "environments" in the config file,
+each with its own API base URL, console base URL, users and projects. Select one with --env, or the HXUTIL_ENV variable.
+
+API calls that fail with a network error, or a rate limit (429) or gateway (502, 503, 504) status, are retried with
+exponential backoff, respecting any Retry-After header. Only idempotent requests (such as GET) are retried.
+Set the number of retries with --retries; 0 disables them. Each attempt times out after --timeout.
+
+Pressing Ctrl-C stops any API calls in progress, and the command wraps up with what it has done so far (such as a
+partial summary or report). Press Ctrl-C again to quit right away.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		policy := hexaclient.DefaultRetryPolicy()
+		policy.MaxRetries = retries
+		hexaclient.SetDefaultRetryPolicy(policy)
+		if timeout < 0 {
+			cmd.SilenceUsage = true
+			return errors.New("--timeout can't be negative")
+		}
+		hexaclient.SetDefaultTimeout(timeout)
+
+		env := envName
+		if env == "" {
+			env = os.Getenv(config.ENV_VAR)
+		}
+		if err := config.UseEnvironment(env); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		return nil
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -22,14 +64,20 @@ var RootCmd = &cobra.Command{
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Ctrl-C cancels the context that commands are run with, so they can stop their work and wrap up.
 func Execute() {
-	err := RootCmd.Execute()
+	ctx, cancel := utils.InterruptContext(context.Background())
+	err := RootCmd.ExecuteContext(ctx)
+	cancel()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
 func init() {
+	// reuse login tokens across runs
+	hexaclient.SetDefaultTokenStore(config.TokenCache{})
+
 	RootCmd.AddCommand(actionCmd.Cmd)
 	RootCmd.AddCommand(apiCmd.Cmd)
 	RootCmd.AddCommand(projectCmd.Cmd)
@@ -39,6 +87,9 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.hxutil.yaml)")
+	RootCmd.PersistentFlags().StringVar(&envName, "env", "", "environment to use, from the config file (defaults to $"+config.ENV_VAR+", or the default environment)")
+	RootCmd.PersistentFlags().IntVar(&retries, "retries", hexaclient.DEFAULT_MAX_RETRIES, "times to retry API calls that fail with a network error, rate limit or gateway error. 0 disables retries.")
+	RootCmd.PersistentFlags().DurationVar(&timeout, "timeout", hexaclient.DEFAULT_TIMEOUT, "time limit for each API call attempt, such as 30s or 2m. 0 disables the time limit.")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -1,10 +1,11 @@
 package action
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/fs"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,307 +13,524 @@ import (
 
 	"github.com/bwebb-hx/hxutil/internal/config"
 	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/pool"
+	"github.com/bwebb-hx/hxutil/internal/report"
 	"github.com/bwebb-hx/hxutil/internal/utils"
 )
 
 const OUT = ".as_temp"
 
+// when false, nothing is read from stdin; projects and users must be given up front
 var INTERACTIVE_MODE = true
 
 type Action struct {
-	ID            string
-	DisplayID     string
-	Name          string
-	P_ID          string
-	D_ID          string
-	DatastoreName string
+	ID                 string
+	DisplayID          string
+	Name               string
+	P_ID               string
+	D_ID               string
+	DatastoreName      string
+	DatastoreDisplayID string
 }
 
-func getAllActionIDs(d_id string, datastoreName string) []Action {
-	resp, err := hexaclient.GetApi(fmt.Sprintf(hexaclient.GetActionsAPI.URI, d_id), nil)
+func getAllActionIDs(ctx context.Context, client *hexaclient.Client, d_id string, datastoreName string, datastoreDisplayID string) ([]Action, error) {
+	resp, err := client.GetApi(ctx, fmt.Sprintf(hexaclient.GetActionsAPI.URI, d_id), nil)
 	if err != nil {
-		log.Println("failed to get action IDs:", err)
-		return nil
+		return nil, fmt.Errorf("failed to get actions of datastore %s: %w", datastoreDisplayID, err)
 	}
 	var getActionsResp hexaclient.GetActionsResponse
 	if err := json.Unmarshal(resp, &getActionsResp); err != nil {
-		log.Println("failed to unmarshal getActions API response")
-		return nil
+		return nil, fmt.Errorf("failed to unmarshal actions of datastore %s: %w", datastoreDisplayID, err)
 	}
 
 	actions := make([]Action, 0)
 	for _, actionDef := range getActionsResp {
 		action := Action{
-			ID:            actionDef.ActionID,
-			DisplayID:     actionDef.DisplayID,
-			Name:          actionDef.Name,
-			P_ID:          actionDef.P_ID,
-			D_ID:          actionDef.D_ID,
-			DatastoreName: datastoreName,
+			ID:                 actionDef.ActionID,
+			DisplayID:          actionDef.DisplayID,
+			Name:               actionDef.Name,
+			P_ID:               actionDef.P_ID,
+			D_ID:               actionDef.D_ID,
+			DatastoreName:      datastoreName,
+			DatastoreDisplayID: datastoreDisplayID,
 		}
 		actions = append(actions, action)
 	}
 
-	return actions
+	return actions, nil
 }
 
-func GetProjectActions(p_id string) []Action {
+// GetProjectActions gives the actions of every datastore in a project. The actions of each datastore are fetched concurrently,
+// and are given in the same order as the datastores.
+//
+// An error is given if the actions of any datastore can't be fetched, since working on only some of them would be misleading.
+func GetProjectActions(ctx context.Context, out io.Writer, client *hexaclient.Client, p_id string) ([]Action, error) {
 	// get all actionscripts IDs for all datastores in the project
-	getDatastoresBytes, err := hexaclient.GetApi(fmt.Sprintf(hexaclient.GetDatastoresAPI.URI, p_id), nil)
+	getDatastoresBytes, err := client.GetApi(ctx, fmt.Sprintf(hexaclient.GetDatastoresAPI.URI, p_id), nil)
 	if err != nil {
-		log.Fatal("failed to get datastores for project:", err)
+		return nil, fmt.Errorf("failed to get datastores for project: %w", err)
 	}
 	var datastores hexaclient.GetDatastoresResponse
 	if err := json.Unmarshal(getDatastoresBytes, &datastores); err != nil {
-		log.Fatal("failed to unmarshal datastores response:", err)
+		return nil, fmt.Errorf("failed to unmarshal datastores response: %w", err)
 	}
 	if len(datastores) == 0 {
-		utils.Warn("No datastores found in project", "P_ID: "+p_id)
-		fmt.Println(string(getDatastoresBytes))
-		return []Action{}
+		utils.WarnTo(out, "No datastores found in project", "P_ID: "+p_id)
+		fmt.Fprintln(out, string(getDatastoresBytes))
+		return []Action{}, nil
 	}
 
+	datastoreActions := pool.Map(ctx, "Fetching actions", datastores, func(ctx context.Context, datastore hexaclient.Datastore) ([]Action, error) {
+		return getAllActionIDs(ctx, client, datastore.DatastoreID, datastore.Name, datastore.DisplayID)
+	})
 	actions := make([]Action, 0)
-	for _, datastore := range datastores {
-		actions = append(actions, getAllActionIDs(datastore.DatastoreID, datastore.Name)...)
+	errs := make([]error, 0)
+	for _, result := range datastoreActions {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+			continue
+		}
+		actions = append(actions, result.Value...)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 
-	return actions
+	return actions, nil
+}
+
+// ScriptRef identifies a single script of an action
+type ScriptRef struct {
+	Action     Action
+	ScriptType string // "pre" or "post"
+}
+
+// label gives how the script is shown in logs and summaries
+func (ref ScriptRef) label() string {
+	return fmt.Sprintf("%s (%s) [%s]", ref.Action.DisplayID, ref.ScriptType, ref.Action.DatastoreName)
 }
 
-func DiffActionScripts(absPath string) {
-	// load config
-	c := config.GetConfig()
-	project := c.SelectProject()
+// scriptRefs gives the pre and post scripts of each action, in order
+func scriptRefs(actions []Action) []ScriptRef {
+	refs := make([]ScriptRef, 0, len(actions)*2)
+	for _, action := range actions {
+		for _, scriptType := range []string{"pre", "post"} {
+			refs = append(refs, ScriptRef{Action: action, ScriptType: scriptType})
+		}
+	}
+	return refs
+}
+
+// DownloadActionScripts downloads scripts concurrently. The results are in the same order as refs.
+func DownloadActionScripts(ctx context.Context, client *hexaclient.Client, refs []ScriptRef) []pool.Result[string] {
+	return pool.Map(ctx, "Downloading actionscripts", refs, func(ctx context.Context, ref ScriptRef) (string, error) {
+		return DownloadActionScript(ctx, client, ref.Action.ID, ref.ScriptType)
+	})
+}
+
+// selectProjectAndLogin determines the project to work on, and gives a client that is logged in to hexabase with a user for it.
+//
+// p_id may be a project ID or display ID, and userEmail must be a user registered in config.
+// Either one is prompted for when left empty, unless interactive mode is off.
+func selectProjectAndLogin(ctx context.Context, p_id, userEmail string) (*config.Project, *hexaclient.Client, error) {
+	c, err := config.GetConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	client := c.NewClient()
+
+	var project *config.Project
+	if p_id != "" {
+		project = c.GetProject(p_id)
+		if project == nil {
+			// projects don't need to be registered to be used
+			project = &config.Project{P_ID: p_id}
+		}
+	} else if INTERACTIVE_MODE {
+		project, err = c.SelectProject(ctx, client)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 	if project == nil {
-		utils.Fatal("no project selected", "")
+		return nil, nil, errors.New("no project selected; specify a project with --project when running non-interactively")
+	}
+
+	if userEmail == "" && !INTERACTIVE_MODE {
+		// fallback to the last user that was used, since we can't ask
+		userEmail = project.LastLoginUser
+		if userEmail == "" {
+			userEmail = c.LastLoginUser
+		}
+		if userEmail == "" {
+			return nil, nil, errors.New("no user selected; specify a user with --user when running non-interactively")
+		}
 	}
 
 	// login to hexabase
-	c.SelectUserAndLogin(project.P_ID)
+	if userEmail == "" {
+		if err := c.SelectUserAndLogin(ctx, client, project.P_ID); err != nil {
+			return nil, nil, err
+		}
+		return project, client, nil
+	}
+	user := c.GetUser(userEmail)
+	if user == nil {
+		return nil, nil, fmt.Errorf("user not found in config: %s; register the user first, or choose a different one", userEmail)
+	}
+	if err := user.Login(ctx, client); err != nil {
+		return nil, nil, err
+	}
+
+	return project, client, nil
+}
+
+// DiffActionScripts diffs the actionscripts of a project against local files in the tree.
+// The returned report has a result for every script that was compared.
+//
+// Scripts are downloaded concurrently. If ctx is cancelled, the scripts that haven't been diffed yet are reported as errors.
+// An error is only given if the diff couldn't be started, in which case no report is given.
+func DiffActionScripts(ctx context.Context, out io.Writer, tree LocalTree, p_id string, userEmail string) (*report.Report, error) {
+	project, client, err := selectProjectAndLogin(ctx, p_id, userEmail)
+	if err != nil {
+		return nil, err
+	}
+	rep := report.New("action diff", fmt.Sprintf("%s [%s]", project.DisplayID, project.P_ID))
 
 	// get all actionscripts IDs for all datastores in the project
-	actions := GetProjectActions(project.P_ID)
+	actions, err := GetProjectActions(ctx, out, client, project.P_ID)
+	if err != nil {
+		return nil, err
+	}
 	if len(actions) == 0 {
-		log.Fatal("No actions found in the given project:", project.P_ID)
+		return nil, fmt.Errorf("no actions found in the given project: %s", project.P_ID)
 	}
 
 	// get all function actionscripts in the project
-	getFunctionsBytes, err := hexaclient.GetApi(hexaclient.UN_GetFunctionActionScriptAPI.URI, map[string]string{
+	getFunctionsBytes, err := client.GetApi(ctx, hexaclient.UN_GetFunctionActionScriptAPI.URI, map[string]string{
 		"p_id": project.P_ID,
 	})
 	if err != nil {
-		log.Fatal("failed to get functions for project:", err)
+		return nil, fmt.Errorf("failed to get functions for project: %w", err)
 	}
 
 	var functions hexaclient.UN_GetFunctionActionScriptResponse
 	if err := json.Unmarshal(getFunctionsBytes, &functions); err != nil {
 		log.Println("failed to get actionscripts for functions")
 	}
+	warnDuplicateScripts(out, tree, actions, functions)
+
+	refs := scriptRefs(actions)
+	downloads := DownloadActionScripts(ctx, client, refs)
 
 	diffFiles := make([]string, 0)
-	totalComps := 0
+	totalComps := len(actions)
 	diffSearchErrs := diffSearchErrs{}
 
-	for _, action := range actions {
-		// find pre scripts
-		diff, searchErrs := diffActionScript(action, absPath, "pre")
-		if searchErrs.errOccurred() {
-			diffSearchErrs.combineCounts(searchErrs)
-
-			if searchErrs.localNotFound > 0 {
-				diffFiles = append(diffFiles, fmt.Sprintf("**LOCAL NOT FOUND**: %s (pre) [%s]", action.DisplayID, action.DatastoreName))
-			}
-		}
-		if diff {
-			diffFiles = append(diffFiles, fmt.Sprintf("%s (pre) [%s]", action.DisplayID, action.DatastoreName))
-		}
-
-		// find post scripts
-		diff, searchErrs = diffActionScript(action, absPath, "post")
-		if searchErrs.errOccurred() {
-			diffSearchErrs.combineCounts(searchErrs)
-
-			if searchErrs.localNotFound > 0 {
-				diffFiles = append(diffFiles, fmt.Sprintf("**LOCAL NOT FOUND**: %s (post) [%s]", action.DisplayID, action.DatastoreName))
-			}
-		}
-		if diff {
-			diffFiles = append(diffFiles, fmt.Sprintf("%s (post) [%s]", action.DisplayID, action.DatastoreName))
-		}
-		totalComps++
+	for i, ref := range refs {
+		diff, searchErrs := diffActionScript(ctx, out, ref.Action, tree, ref.ScriptType, downloads[i], rep)
+		diffSearchErrs.combineCounts(searchErrs)
+		diffFiles = append(diffFiles, actionDiffLines(ref, diff, searchErrs)...)
 	}
 
-	diffFunctions, diffFnSearchErrs := diffFunctionActionScripts(absPath, functions)
+	diffFunctions, diffFnSearchErrs := diffFunctionActionScripts(ctx, out, tree, functions, rep)
 	diffFiles = append(diffFiles, diffFunctions...)
 	if diffFnSearchErrs.errOccurred() {
 		diffSearchErrs.combineCounts(diffFnSearchErrs)
 	}
 
-	fmt.Println("\nSUMMARY\n=======")
-	fmt.Print("ActionScripts with local differences:\n\n")
+	fmt.Fprintln(out, "\nSUMMARY\n=======")
+	fmt.Fprint(out, "ActionScripts with local differences:\n\n")
 	for _, diffFile := range diffFiles {
-		fmt.Println(diffFile)
+		fmt.Fprintln(out, diffFile)
 	}
-	fmt.Println("\nTotal files checked:", totalComps)
+	fmt.Fprintln(out, "\nTotal files checked:", totalComps)
 	if diffSearchErrs.errOccurred() {
-		fmt.Println(diffSearchErrs)
+		fmt.Fprintln(out, diffSearchErrs)
 	}
-	fmt.Println("=======\n ")
+	fmt.Fprintln(out, "=======\n ")
+
+	return rep, nil
+}
+
+// actionDiffLines gives the summary lines for an action script that was diffed
+func actionDiffLines(ref ScriptRef, diff bool, searchErrs diffSearchErrs) []string {
+	name := fmt.Sprintf("%s (%s) [%s]", ref.Action.DisplayID, ref.ScriptType, ref.Action.DatastoreName)
+	lines := make([]string, 0)
+	if searchErrs.localNotFound > 0 {
+		lines = append(lines, "**LOCAL NOT FOUND**: "+name)
+	}
+	if searchErrs.remoteNotFound > 0 {
+		lines = append(lines, "**REMOTE NOT FOUND**: "+name)
+	}
+	if searchErrs.ambiguous > 0 {
+		lines = append(lines, "**AMBIGUOUS**: "+name)
+	}
+	if searchErrs.localReadErr > 0 {
+		lines = append(lines, "**LOCAL NOT READABLE**: "+name)
+	}
+	if searchErrs.notCompiled > 0 {
+		lines = append(lines, "**NOT COMPARED (TypeScript)**: "+name)
+	}
+	if diff {
+		lines = append(lines, name)
+	}
+	return lines
 }
 
 type diffSearchErrs struct {
 	localNotFound  int
+	remoteNotFound int
+	ambiguous      int
 	respUnexpected int
 	walkDirErr     int
+	localReadErr   int
+	notCompiled    int // TypeScript sources, which can't be compared with the compiled scripts in hexabase
+	interrupted    int
 }
 
 func (dse diffSearchErrs) String() string {
 	out := fmt.Sprintf("%s: %v\n", "local scripts not found", dse.localNotFound)
+	out += fmt.Sprintf("%s: %v\n", "remote scripts not found", dse.remoteNotFound)
+	out += fmt.Sprintf("%s: %v\n", "ambiguous local scripts", dse.ambiguous)
 	out += fmt.Sprintf("%s: %v\n", "unexpected API responses", dse.respUnexpected)
 	out += fmt.Sprintf("%s: %v", "errors while walking project files", dse.walkDirErr)
+	if dse.localReadErr > 0 {
+		out += fmt.Sprintf("\n%s: %v", "local scripts that couldn't be read", dse.localReadErr)
+	}
+	if dse.notCompiled > 0 {
+		out += fmt.Sprintf("\n%s: %v", "TypeScript sources not compared", dse.notCompiled)
+	}
+	if dse.interrupted > 0 {
+		out += fmt.Sprintf("\n%s: %v", "not diffed (interrupted)", dse.interrupted)
+	}
 	return out
 }
 
 func (dse diffSearchErrs) errOccurred() bool {
-	return dse.localNotFound > 0 || dse.respUnexpected > 0 || dse.walkDirErr > 0
+	return dse.localNotFound > 0 || dse.remoteNotFound > 0 || dse.ambiguous > 0 || dse.respUnexpected > 0 || dse.walkDirErr > 0 || dse.localReadErr > 0 || dse.notCompiled > 0 || dse.interrupted > 0
 }
 
 func (dse *diffSearchErrs) combineCounts(searchErrs diffSearchErrs) {
 	dse.localNotFound += searchErrs.localNotFound
+	dse.remoteNotFound += searchErrs.remoteNotFound
+	dse.ambiguous += searchErrs.ambiguous
 	dse.respUnexpected += searchErrs.respUnexpected
 	dse.walkDirErr += searchErrs.walkDirErr
+	dse.localReadErr += searchErrs.localReadErr
+	dse.notCompiled += searchErrs.notCompiled
+	dse.interrupted += searchErrs.interrupted
 }
 
-func diffFunctionActionScripts(absPath string, functions hexaclient.UN_GetFunctionActionScriptResponse) ([]string, diffSearchErrs) {
+// notCompiledResult records a TypeScript source, which isn't diffed since hexabase only has its compiled output
+func (dse *diffSearchErrs) notCompiledResult(result report.Result, localPath string, rep *report.Report) {
+	dse.notCompiled++
+	result.Status = report.StatusSkipped
+	result.Message = "TypeScript source; compile it to compare: " + localPath
+	rep.Add(result)
+}
+
+// localReadErrResult records a local script that was found, but couldn't be read to diff
+func (dse *diffSearchErrs) localReadErrResult(out io.Writer, result report.Result, err error, rep *report.Report) {
+	utils.ErrorTo(out, "failed to read local script", err.Error())
+	dse.localReadErr++
+	result.Status = report.StatusError
+	result.Message = err.Error()
+	rep.Add(result)
+}
+
+// interruptedResult records a script that wasn't diffed because ctx was cancelled
+func (dse *diffSearchErrs) interruptedResult(result report.Result, rep *report.Report) {
+	dse.interrupted++
+	result.Status = report.StatusError
+	result.Message = "interrupted"
+	rep.Add(result)
+}
+
+func diffFunctionActionScripts(ctx context.Context, out io.Writer, tree LocalTree, functions hexaclient.UN_GetFunctionActionScriptResponse, rep *report.Report) ([]string, diffSearchErrs) {
 	diffFiles := make([]string, 0)
 	searchErrs := diffSearchErrs{}
 
 	for _, function := range functions {
+		result := report.Result{
+			ActionID:   function.FunctionID,
+			DisplayID:  function.DisplayID,
+			ScriptType: "function",
+		}
+
 		actionscript := strings.TrimSpace(function.Pre.Script)
 		if actionscript == "" {
 			log.Println("empty function?:", function.DisplayID)
 			continue
 		}
+		if ctx.Err() != nil {
+			searchErrs.interruptedResult(result, rep)
+			continue
+		}
 
 		// find the corresponding file
-		stop := errors.New("stop")
-		found := false
-		diffVal := false
-		err := filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if !d.IsDir() && strings.HasPrefix(d.Name(), function.DisplayID) {
-				if strings.HasSuffix(d.Name(), ".js") {
-					// match found! get diff results
-					diffVal = diff(path, actionscript, d.Name(), "FUNCTION")
-					found = true
-					return stop
-				}
-			}
-			return nil
-		})
-		if err != nil && !errors.Is(err, stop) {
+		localPath, err := tree.FindFunctionScript(function.FunctionID, function.DisplayID)
+		var ambiguousErr *AmbiguousMatchError
+		if errors.As(err, &ambiguousErr) {
+			utils.WarnTo(out, "can't tell which local file to diff", err.Error())
+			searchErrs.ambiguous++
+			diffFiles = append(diffFiles, fmt.Sprintf("**AMBIGUOUS**: %s [FUNCTION]", function.DisplayID))
+			result.Status = report.StatusAmbiguous
+			result.Message = err.Error()
+			rep.Add(result)
+			continue
+		}
+		if err != nil {
 			log.Println("an error occurred while walking project files:", err)
 			searchErrs.walkDirErr++
+			result.Status = report.StatusError
+			result.Message = err.Error()
+			rep.Add(result)
+			continue
+		}
+		found := localPath != ""
+		if found && !pushable(localPath) {
+			searchErrs.notCompiledResult(result, localPath, rep)
+			diffFiles = append(diffFiles, fmt.Sprintf("**NOT COMPARED (TypeScript)**: %s [FUNCTION]", function.DisplayID))
 			continue
 		}
 		if !found {
 			log.Println("failed to find function:", function.DisplayID)
 			searchErrs.localNotFound++
 			diffFiles = append(diffFiles, fmt.Sprintf("**LOCAL NOT FOUND**: %s [FUNCTION]", function.DisplayID))
+			result.Status = report.StatusMissingLocal
+			rep.Add(result)
 			continue
 		}
+		// match found! get diff results
+		diffVal, unifiedDiff, err := diff(out, localPath, actionscript, filepath.Base(localPath), "FUNCTION")
+		if err != nil {
+			searchErrs.localReadErrResult(out, result, err, rep)
+			diffFiles = append(diffFiles, fmt.Sprintf("**LOCAL NOT READABLE**: %s [FUNCTION]", function.DisplayID))
+			continue
+		}
+		result.Diff = unifiedDiff
+		result.Status = report.StatusMatch
 		if diffVal {
 			diffFiles = append(diffFiles, function.DisplayID+" [FUNCTION]")
+			result.Status = report.StatusDiff
 		}
+		result.Message = localPath
+		rep.Add(result)
 	}
 
 	return diffFiles, searchErrs
 }
 
-func DownloadActionScript(actionID string, scriptType string) (string, error) {
-	downloadResp, err := hexaclient.GetApi(fmt.Sprintf(hexaclient.DownloadActionScriptAPI.URI, actionID), map[string]string{
+// DownloadActionScript gives the script of an action. An empty script is given if the action doesn't have one.
+func DownloadActionScript(ctx context.Context, client *hexaclient.Client, actionID string, scriptType string) (string, error) {
+	downloadResp, err := client.GetApi(ctx, fmt.Sprintf(hexaclient.DownloadActionScriptAPI.URI, actionID), map[string]string{
 		"script_type": scriptType,
 	})
 	if err != nil {
-		return "", err
-	}
-	actionscript := strings.TrimSpace(string(downloadResp))
-	if actionscript == "" {
-		return "", nil
-	}
-
-	// check for errors
-	if actionscript[0] == '{' {
-		var jsonData map[string]interface{}
-		if err := json.Unmarshal(downloadResp, &jsonData); err != nil {
-			utils.Error("tried to unmarshal actionscript json response but error occurred", err.Error())
-			return "", errors.New("failed to unmarshal as json; unexpected response format")
-		}
-		errorCode, exists := jsonData["error_code"]
-		if exists {
-			if errorCode == "NOT_FOUND" {
-				return "", nil
-			}
-			if errorCode == "SYSTEM_ERROR" {
-				if jsonData["error"] == "empty script" {
-					return "", nil
-				}
-			}
+		if hexaclient.IsNotFound(err) || isEmptyScriptError(err) {
+			return "", nil
 		}
-		utils.Warn("unexpected actionscript download response:", actionscript)
-		return "", errors.New("unexpected actionscript download response")
+		return "", err
 	}
+	return strings.TrimSpace(string(downloadResp)), nil
+}
 
-	return actionscript, nil
+// isEmptyScriptError reports whether err is the error given when downloading an action script that was never set
+func isEmptyScriptError(err error) bool {
+	var apiErr *hexaclient.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == hexaclient.ERROR_CODE_SYSTEM_ERROR && apiErr.Message == "empty script"
 }
 
-func diffActionScript(action Action, absPath string, scriptType string) (bool, diffSearchErrs) {
+// diffActionScript diffs an action's script, which has already been downloaded, against its local file
+func diffActionScript(ctx context.Context, out io.Writer, action Action, tree LocalTree, scriptType string, download pool.Result[string], rep *report.Report) (bool, diffSearchErrs) {
 	stats := diffSearchErrs{}
 	diffVal := false
 
 	if scriptType != "post" && scriptType != "pre" {
-		utils.Error("unsupported script type: "+scriptType, "")
+		utils.ErrorTo(out, "unsupported script type: "+scriptType, "")
 		return false, stats
 	}
 
-	// download actionscript
-	actionscript, err := DownloadActionScript(action.ID, scriptType)
+	result := report.Result{
+		ActionID:   action.ID,
+		DisplayID:  action.DisplayID,
+		Datastore:  action.DatastoreName,
+		ScriptType: scriptType,
+	}
+
+	if ctx.Err() != nil {
+		stats.interruptedResult(result, rep)
+		return false, stats
+	}
+
+	actionscript, err := download.Value, download.Err
 	if err != nil {
-		utils.Error("error occurred while fetching actionscript", err.Error())
+		utils.ErrorTo(out, "error occurred while fetching actionscript", err.Error())
 		stats.respUnexpected++
+		result.Status = report.StatusError
+		result.Message = err.Error()
+		rep.Add(result)
+		return false, stats
+	}
+
+	localPath, err := tree.FindActionScript(action, scriptType)
+	var ambiguousErr *AmbiguousMatchError
+	if errors.As(err, &ambiguousErr) {
+		utils.WarnTo(out, "can't tell which local file to diff", err.Error())
+		stats.ambiguous++
+		result.Status = report.StatusAmbiguous
+		result.Message = err.Error()
+		rep.Add(result)
+		return false, stats
+	}
+	if err != nil {
+		log.Println("an error occurred while walking project files:", err)
+		stats.walkDirErr++
+		result.Status = report.StatusError
+		result.Message = err.Error()
+		rep.Add(result)
 		return false, stats
 	}
+	found := localPath != ""
+
 	if actionscript == "" {
+		// no script in hexabase; only worth reporting if there's one locally
+		if found {
+			stats.remoteNotFound++
+			result.Status = report.StatusMissingRemote
+			result.Message = localPath
+			rep.Add(result)
+		}
 		return false, stats
 	}
 
-	stop := errors.New("stop")
-	found := false
-	err = filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
+	if found && !pushable(localPath) {
+		stats.notCompiledResult(result, localPath, rep)
+		return false, stats
+	}
+	if found {
+		// match found! get diff results
+		var err error
+		diffVal, result.Diff, err = diff(out, localPath, actionscript, filepath.Base(localPath), action.DatastoreName)
 		if err != nil {
-			return err
+			stats.localReadErrResult(out, result, err, rep)
+			return false, stats
 		}
-		if !d.IsDir() && strings.HasPrefix(d.Name(), action.DisplayID) {
-			if strings.HasSuffix(d.Name(), scriptType+".js") {
-				// match found! get diff results
-				diffVal = diff(path, actionscript, d.Name(), action.DatastoreName)
-				found = true
-				return stop
-			}
+		result.Status = report.StatusMatch
+		if diffVal {
+			result.Status = report.StatusDiff
 		}
-		return nil
-	})
-	if err != nil && !errors.Is(err, stop) {
-		log.Println("an error occurred while walking project files:", err)
-		stats.walkDirErr++
+		result.Message = localPath
+	} else {
+		result.Status = report.StatusMissingLocal
 	}
+	rep.Add(result)
+
 	if !found {
 		log.Println("failed to find actionscript in local:", action.Name, fmt.Sprintf("(%s)", action.DatastoreName))
 		if len(actionscript) > 15 {
-			fmt.Println("actionscript snippet:", actionscript[:10]+"...")
+			fmt.Fprintln(out, "actionscript snippet:", actionscript[:10]+"...")
 		} else {
 			log.Println("actionscript snippet:", actionscript)
 		}
@@ -322,24 +540,24 @@ func diffActionScript(action Action, absPath string, scriptType string) (bool, d
 	return diffVal, stats
 }
 
-// returns true if a difference is found
-func diff(local, remoteString, fileName, datastoreName string) bool {
+// returns true if a difference is found, along with the difference as a unified diff.
+// An error is given if the local file can't be read.
+func diff(out io.Writer, local, remoteString, fileName, datastoreName string) (bool, string, error) {
 	localBytes, err := os.ReadFile(local)
 	if err != nil {
-		log.Println("failed to load local file:", err)
-		return false
+		return false, "", fmt.Errorf("failed to read local file: %w", err)
 	}
 
 	diff := utils.GetDiff(string(localBytes), remoteString)
 	if diff != "" {
-		fmt.Println("\n===")
-		fmt.Println(fileName, fmt.Sprintf("(%s)\n", datastoreName))
-		fmt.Println(diff)
-		fmt.Println("===")
+		fmt.Fprintln(out, "\n===")
+		fmt.Fprintln(out, fileName, fmt.Sprintf("(%s)\n", datastoreName))
+		fmt.Fprintln(out, diff)
+		fmt.Fprintln(out, "===")
 		if INTERACTIVE_MODE {
 			utils.EnterToContinue()
 		}
-		return true
+		return true, utils.GetUnifiedDiff(string(localBytes), remoteString, "local/"+fileName, "remote/"+fileName), nil
 	}
-	return false
+	return false, "", nil
 }
@@ -0,0 +1,195 @@
+package action
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bwebb-hx/hxutil/internal/ignore"
+)
+
+// name of the files whose patterns are skipped when indexing, in any directory of the tree
+const GITIGNORE_FILE = ".gitignore"
+
+// paths that are always skipped when indexing, unless un-ignored (e.g. "!node_modules/") in the ignore list
+var DEFAULT_IGNORE = []string{"node_modules/", ".git/"}
+
+// localIndex is every script file in the tree, found in a single walk.
+// Files are indexed by name, which holds the display ID (and script type) of the script.
+type localIndex struct {
+	once   sync.Once
+	files  []string            // all indexed files, relative to the root with "/" separators, in walk order
+	byName map[string][]string // file name -> paths of files with that name
+	err    error
+}
+
+// index gives the index of the tree, walking it the first time it's needed
+func (tree LocalTree) index() *localIndex {
+	idx := tree.idx
+	if idx == nil {
+		// trees not made with NewLocalTree aren't cached
+		idx = &localIndex{}
+	}
+	idx.once.Do(func() {
+		idx.build(tree)
+	})
+	return idx
+}
+
+// build walks the tree. Paths are skipped if they are ignored by .gitignore files (from GitignoreRoot down) or the ignore list
+// of the tree. All of them are evaluated in order, in one matcher: outer .gitignore files first, then inner ones,
+// then the ignore list, so the ignore list can un-ignore anything that .gitignore files ignore.
+func (idx *localIndex) build(tree LocalTree) {
+	idx.files = make([]string, 0)
+	idx.byName = make(map[string][]string)
+
+	// the matchers work with paths relative to the gitignore root, so patterns of .gitignore files above Root are anchored correctly
+	gitRoot, rootRel := tree.gitignoreRoot()
+	gitPath := func(relPath string) string {
+		return path.Join(rootRel, relPath)
+	}
+
+	// .gitignore files from the gitignore root down to (not including) Root
+	gitignored := ignore.New(nil)
+	dir := ""
+	for _, segment := range append([]string{""}, strings.Split(rootRel, "/")...) {
+		dir = path.Join(dir, segment)
+		if dir == rootRel {
+			break
+		}
+		patterns, err := readGitignore(filepath.Join(gitRoot, filepath.FromSlash(dir), GITIGNORE_FILE))
+		if err != nil {
+			idx.err = err
+			return
+		}
+		gitignored.AddRelative(dir, patterns...)
+	}
+
+	// each directory (relative to Root) has the .gitignore patterns that apply under it, and the matcher for its entries
+	gitignoredIn := make(map[string]*ignore.Matcher)
+	matchers := make(map[string]*ignore.Matcher)
+	parentOf := func(relPath string) string {
+		if parent := path.Dir(relPath); parent != "." {
+			return parent
+		}
+		return ""
+	}
+	gitignoredIn[""] = gitignored
+
+	idx.err = filepath.WalkDir(tree.Root, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(tree.Root, fullPath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		parent := parentOf(relPath)
+		if relPath != "." && matchers[parent].Match(gitPath(relPath), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			// .gitignore patterns apply to everything under the directory the file is in
+			patterns, err := readGitignore(filepath.Join(fullPath, GITIGNORE_FILE))
+			if err != nil {
+				return err
+			}
+			if relPath == "." {
+				relPath = ""
+			}
+			g := gitignoredIn[parent]
+			if len(patterns) > 0 || relPath == "" {
+				g = g.Clone()
+				g.AddRelative(gitPath(relPath), patterns...)
+				m := g.Clone()
+				m.Merge(rootRel, tree.Ignore)
+				matchers[relPath] = m
+			} else {
+				matchers[relPath] = matchers[parent]
+			}
+			gitignoredIn[relPath] = g
+			return nil
+		}
+		if !isScriptFile(d.Name()) {
+			return nil
+		}
+		idx.files = append(idx.files, relPath)
+		idx.byName[d.Name()] = append(idx.byName[d.Name()], relPath)
+		return nil
+	})
+}
+
+// gitignoreRoot gives the directory that .gitignore files are read from (down to Root), and the path of Root relative to it.
+func (tree LocalTree) gitignoreRoot() (string, string) {
+	if tree.GitignoreRoot == "" {
+		return tree.Root, ""
+	}
+	rel, err := filepath.Rel(tree.GitignoreRoot, tree.Root)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		// the gitignore root has to contain Root
+		return tree.Root, ""
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		rel = ""
+	}
+	return tree.GitignoreRoot, rel
+}
+
+// find gives the files that match the pattern. names are the file names a match could have, if they are known;
+// otherwise every file is checked.
+func (idx *localIndex) find(pattern *regexp.Regexp, names []string) []string {
+	candidates := idx.files
+	if names != nil {
+		candidates = make([]string, 0)
+		for _, name := range names {
+			candidates = append(candidates, idx.byName[name]...)
+		}
+	}
+	matches := make([]string, 0)
+	for _, relPath := range candidates {
+		if pattern.MatchString(path.Clean(relPath)) {
+			matches = append(matches, relPath)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func isScriptFile(name string) bool {
+	for _, ext := range SCRIPT_EXTENSIONS {
+		if strings.HasSuffix(name, "."+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// readGitignore reads the patterns of a .gitignore file. No patterns are given if the file doesn't exist.
+func readGitignore(gitignorePath string) ([]string, error) {
+	f, err := os.Open(gitignorePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,164 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/bwebb-hx/hxutil/internal/ignore"
+)
+
+// LocalTree is the local directory of scripts that a project is diffed against, pulled into and pushed from.
+//
+// Scripts are located with (in order of priority) the manifest, then the templates.
+type LocalTree struct {
+	Root             string          // absolute path of the directory
+	FunctionsDir     string          // where function scripts are pulled to, relative to Root. not used if FunctionTemplate gives a single path.
+	Ignore           *ignore.Matcher // paths (relative to Root) to skip when looking for scripts. may be nil.
+	GitignoreRoot    string          // directory (Root or one of its parents, such as the repo root) that .gitignore files are read from, down to Root. defaults to Root.
+	ActionTemplate   string          // where action scripts are, relative to Root. defaults to DEFAULT_ACTION_TEMPLATE.
+	FunctionTemplate string          // where function scripts are, relative to Root. defaults to DEFAULT_FUNCTION_TEMPLATE.
+	Manifest         *Manifest       // explicit paths for actions and functions. may be nil.
+
+	idx *localIndex
+}
+
+// NewLocalTree gives a tree at root, with the default layout and ignore list.
+// The tree is only walked once, the first time a script is looked for.
+func NewLocalTree(root string) LocalTree {
+	return LocalTree{
+		Root:         root,
+		FunctionsDir: FUNCTIONS_DIR,
+		Ignore:       ignore.New(DEFAULT_IGNORE),
+		idx:          &localIndex{},
+	}
+}
+
+// Validate checks that the templates of the tree are usable
+func (tree LocalTree) Validate() error {
+	if err := ValidateTemplate(tree.ActionTemplate, DATASTORE_PLACEHOLDER, ACTION_PLACEHOLDER, TYPE_PLACEHOLDER, EXT_PLACEHOLDER, SEP_PLACEHOLDER); err != nil {
+		return err
+	}
+	return ValidateTemplate(tree.FunctionTemplate, FUNCTION_PLACEHOLDER, EXT_PLACEHOLDER, SEP_PLACEHOLDER)
+}
+
+func (tree LocalTree) actionTemplate() string {
+	if tree.ActionTemplate == "" {
+		return DEFAULT_ACTION_TEMPLATE
+	}
+	return tree.ActionTemplate
+}
+
+func (tree LocalTree) functionTemplate() string {
+	if tree.FunctionTemplate == "" {
+		return DEFAULT_FUNCTION_TEMPLATE
+	}
+	return tree.FunctionTemplate
+}
+
+func (tree LocalTree) actionPattern(action Action, scriptType string) *regexp.Regexp {
+	return compileTemplate(tree.actionTemplate(), actionValues(action, scriptType))
+}
+
+func (tree LocalTree) functionPattern(displayID string) *regexp.Regexp {
+	return compileTemplate(tree.functionTemplate(), functionValues(displayID))
+}
+
+// ActionScriptPath gives the path (relative to the root) where an action's script is pulled to.
+func (tree LocalTree) ActionScriptPath(action Action, scriptType string) string {
+	if manifestPath, exists := tree.Manifest.actionPath(action, scriptType); exists {
+		return filepath.FromSlash(manifestPath)
+	}
+	if tree.ActionTemplate != "" {
+		if expanded, ok := expandTemplate(tree.ActionTemplate, actionValues(action, scriptType)); ok {
+			return filepath.FromSlash(expanded)
+		}
+	}
+	return LocalScriptPath(action, scriptType)
+}
+
+// FunctionScriptPath gives the path (relative to the root) where a function's script is pulled to.
+//
+// Default layout: <functions dir>/<function display id>.js
+func (tree LocalTree) FunctionScriptPath(functionID, displayID string) string {
+	if manifestPath, exists := tree.Manifest.functionPath(functionID, displayID); exists {
+		return filepath.FromSlash(manifestPath)
+	}
+	if tree.FunctionTemplate != "" {
+		if expanded, ok := expandTemplate(tree.FunctionTemplate, functionValues(displayID)); ok {
+			return filepath.FromSlash(expanded)
+		}
+	}
+	functionsDir := tree.FunctionsDir
+	if functionsDir == "" {
+		functionsDir = FUNCTIONS_DIR
+	}
+	return filepath.Join(functionsDir, displayID+"."+SCRIPT_EXTENSIONS[0])
+}
+
+// FindActionScript gives the absolute path of an action's local script. An empty path is returned if there isn't one,
+// and an AmbiguousMatchError if more than one file matches.
+// Of several matches, the one in the action's datastore directory (where it's pulled to by default) is preferred,
+// so actions in different datastores that share a display ID can be told apart.
+func (tree LocalTree) FindActionScript(action Action, scriptType string) (string, error) {
+	if manifestPath, exists := tree.Manifest.actionPath(action, scriptType); exists {
+		return tree.findManifestPath(manifestPath)
+	}
+	name := fmt.Sprintf("%s (%s) [%s]", action.DisplayID, scriptType, action.DatastoreName)
+	values := actionValues(action, scriptType)
+	return tree.findScript(name, compileTemplate(tree.actionTemplate(), values), templateNames(tree.actionTemplate(), values), datastoreDir(action))
+}
+
+// FindFunctionScript gives the absolute path of a function's local script. An empty path is returned if there isn't one,
+// and an AmbiguousMatchError if more than one file matches.
+func (tree LocalTree) FindFunctionScript(functionID, displayID string) (string, error) {
+	if manifestPath, exists := tree.Manifest.functionPath(functionID, displayID); exists {
+		return tree.findManifestPath(manifestPath)
+	}
+	values := functionValues(displayID)
+	return tree.findScript(displayID+" [FUNCTION]", compileTemplate(tree.functionTemplate(), values), templateNames(tree.functionTemplate(), values), "")
+}
+
+// findManifestPath checks that a path given by the manifest exists
+func (tree LocalTree) findManifestPath(manifestPath string) (string, error) {
+	fullPath := filepath.Join(tree.Root, filepath.FromSlash(manifestPath))
+	if _, err := os.Stat(fullPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return fullPath, nil
+}
+
+// findScript looks up the file that matches the pattern in the index. names are the file names a match could have, if known.
+// If more than one file matches, the ones directly in a directory named preferDir (if set) are preferred.
+// An empty path is returned if no file matches. Ambiguous matches are given as paths relative to the root.
+func (tree LocalTree) findScript(name string, pattern *regexp.Regexp, names []string, preferDir string) (string, error) {
+	idx := tree.index()
+	if idx.err != nil {
+		return "", idx.err
+	}
+	matches := idx.find(pattern, names)
+	if len(matches) > 1 && preferDir != "" {
+		preferred := make([]string, 0)
+		for _, match := range matches {
+			if path.Base(path.Dir(match)) == preferDir {
+				preferred = append(preferred, match)
+			}
+		}
+		if len(preferred) > 0 {
+			matches = preferred
+		}
+	}
+	if len(matches) > 1 {
+		return "", &AmbiguousMatchError{Script: name, Paths: matches}
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+	return filepath.Join(tree.Root, filepath.FromSlash(matches[0])), nil
+}
@@ -0,0 +1,228 @@
+package action
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/ignore"
+	"github.com/bwebb-hx/hxutil/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// placeholders that can be used in mapping templates
+const (
+	DATASTORE_PLACEHOLDER = "{datastore}" // datastore display ID (or ID, if it has no display ID)
+	ACTION_PLACEHOLDER    = "{action}"    // action display ID
+	TYPE_PLACEHOLDER      = "{type}"      // "pre" or "post"
+	FUNCTION_PLACEHOLDER  = "{function}"  // function display ID
+	EXT_PLACEHOLDER       = "{ext}"       // any supported script extension
+	SEP_PLACEHOLDER       = "{sep}"       // any of the separators ".", "_" or "-"
+)
+
+// by default, scripts can be anywhere in the tree, as long as they are named after the action or function
+const (
+	DEFAULT_ACTION_TEMPLATE   = "**/" + ACTION_PLACEHOLDER + SEP_PLACEHOLDER + TYPE_PLACEHOLDER + "." + EXT_PLACEHOLDER
+	DEFAULT_FUNCTION_TEMPLATE = "**/" + FUNCTION_PLACEHOLDER + "." + EXT_PLACEHOLDER
+)
+
+// extensions of local script files. scripts are always pulled as the first one.
+var SCRIPT_EXTENSIONS = []string{"js", "ts"}
+
+var placeholderRegex = regexp.MustCompile(`\{[a-z]+\}`)
+
+// Manifest maps actions and functions to explicit paths (relative to the root of the local tree).
+// Keys can be either IDs or display IDs; IDs are looked up first.
+type Manifest struct {
+	Actions   map[string]map[string]string `yaml:"actions"`   // action -> script type ("pre" or "post") -> path
+	Functions map[string]string            `yaml:"functions"` // function -> path
+}
+
+// LoadManifest reads a manifest file
+func LoadManifest(manifestPath string) (*Manifest, error) {
+	manifestBytes, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := yaml.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", manifestPath, err)
+	}
+	return &manifest, nil
+}
+
+func (m *Manifest) actionPath(action Action, scriptType string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	for _, key := range []string{action.ID, action.DisplayID} {
+		if scripts, exists := m.Actions[key]; exists {
+			scriptPath, exists := scripts[scriptType]
+			return scriptPath, exists
+		}
+	}
+	return "", false
+}
+
+func (m *Manifest) functionPath(functionID, displayID string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	for _, key := range []string{functionID, displayID} {
+		if scriptPath, exists := m.Functions[key]; exists {
+			return scriptPath, true
+		}
+	}
+	return "", false
+}
+
+// AmbiguousMatchError is given when more than one local file could be the script for an action or function
+type AmbiguousMatchError struct {
+	Script string
+	Paths  []string
+}
+
+func (e *AmbiguousMatchError) Error() string {
+	return fmt.Sprintf("%v local files match %s: %s", len(e.Paths), e.Script, strings.Join(e.Paths, ", "))
+}
+
+// ValidateTemplate checks that a template only uses placeholders that are allowed in it
+func ValidateTemplate(template string, allowed ...string) error {
+	for _, placeholder := range placeholderRegex.FindAllString(template, -1) {
+		valid := false
+		for _, a := range allowed {
+			if placeholder == a {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown placeholder %s in template %q (allowed: %s)", placeholder, template, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// compileTemplate converts a template into a regular expression that matches paths (relative to the root, with "/" separators).
+// Text outside of placeholders is treated as a glob.
+func compileTemplate(template string, values map[string]string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	last := 0
+	for _, loc := range placeholderRegex.FindAllStringIndex(template, -1) {
+		sb.WriteString(ignore.GlobToRegexp(template[last:loc[0]]))
+		switch placeholder := template[loc[0]:loc[1]]; placeholder {
+		case EXT_PLACEHOLDER:
+			sb.WriteString("(" + strings.Join(SCRIPT_EXTENSIONS, "|") + ")")
+		case SEP_PLACEHOLDER:
+			sb.WriteString("[._-]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(values[placeholder]))
+		}
+		last = loc[1]
+	}
+	sb.WriteString(ignore.GlobToRegexp(template[last:]))
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// expandTemplate fills in a template to give a single path. false is returned if the template has wildcards,
+// since then there isn't just one path it could be.
+func expandTemplate(template string, values map[string]string) (string, bool) {
+	expanded := placeholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch placeholder {
+		case EXT_PLACEHOLDER:
+			return SCRIPT_EXTENSIONS[0]
+		case SEP_PLACEHOLDER:
+			return "."
+		}
+		return values[placeholder]
+	})
+	if strings.ContainsAny(expanded, "*?") {
+		return "", false
+	}
+	return path.Clean(expanded), true
+}
+
+// templateNames gives every file name that a template can match. nil is returned if the file name has wildcards.
+func templateNames(template string, values map[string]string) []string {
+	name := path.Base(template)
+	if strings.ContainsAny(name, "*?") {
+		return nil
+	}
+	names := []string{""}
+	last := 0
+	for _, loc := range placeholderRegex.FindAllStringIndex(name, -1) {
+		options := []string{values[name[loc[0]:loc[1]]]}
+		switch name[loc[0]:loc[1]] {
+		case EXT_PLACEHOLDER:
+			options = SCRIPT_EXTENSIONS
+		case SEP_PLACEHOLDER:
+			options = []string{".", "_", "-"}
+		}
+		expanded := make([]string, 0, len(names)*len(options))
+		for _, prefix := range names {
+			for _, option := range options {
+				expanded = append(expanded, prefix+name[last:loc[0]]+option)
+			}
+		}
+		names = expanded
+		last = loc[1]
+	}
+	for i := range names {
+		names[i] += name[last:]
+	}
+	return names
+}
+
+func actionValues(action Action, scriptType string) map[string]string {
+	return map[string]string{
+		DATASTORE_PLACEHOLDER: datastoreDir(action),
+		ACTION_PLACEHOLDER:    action.DisplayID,
+		TYPE_PLACEHOLDER:      scriptType,
+	}
+}
+
+func functionValues(displayID string) map[string]string {
+	return map[string]string{
+		FUNCTION_PLACEHOLDER: displayID,
+	}
+}
+
+// warnDuplicateScripts warns about actions and functions whose local scripts can't be told apart,
+// such as actions in the same datastore that share a display ID. Actions in different datastores are told apart
+// by the datastore directory their scripts are in (see FindActionScript).
+func warnDuplicateScripts(out io.Writer, tree LocalTree, actions []Action, functions hexaclient.UN_GetFunctionActionScriptResponse) {
+	matchers := make(map[string][]string)
+	for _, action := range actions {
+		key := tree.actionPattern(action, "pre").String() + " in " + datastoreDir(action)
+		if manifestPath, exists := tree.Manifest.actionPath(action, "pre"); exists {
+			key = "manifest:" + manifestPath
+		}
+		matchers[key] = append(matchers[key], fmt.Sprintf("%s [%s]", action.DisplayID, action.DatastoreName))
+	}
+	for _, function := range functions {
+		key := tree.functionPattern(function.DisplayID).String()
+		if manifestPath, exists := tree.Manifest.functionPath(function.FunctionID, function.DisplayID); exists {
+			key = "manifest:" + manifestPath
+		}
+		matchers[key] = append(matchers[key], function.DisplayID+" [FUNCTION]")
+	}
+
+	keys := make([]string, 0, len(matchers))
+	for key := range matchers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if len(matchers[key]) > 1 {
+			utils.WarnTo(out, "scripts share the same local file name: "+strings.Join(matchers[key], ", "),
+				"use "+DATASTORE_PLACEHOLDER+" in the action template, or map them by ID in a manifest")
+		}
+	}
+}
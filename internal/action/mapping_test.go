@@ -0,0 +1,225 @@
+package action
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testActionValues = map[string]string{
+	DATASTORE_PLACEHOLDER: "orders",
+	ACTION_PLACEHOLDER:    "create",
+	TYPE_PLACEHOLDER:      "pre",
+}
+
+func TestCompileTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		values   map[string]string
+		matches  []string
+		rejects  []string
+	}{
+		{
+			name:     "default action template",
+			template: DEFAULT_ACTION_TEMPLATE,
+			values:   testActionValues,
+			matches:  []string{"create.pre.js", "create_pre.ts", "create-pre.js", "a/b/create.pre.js"},
+			rejects:  []string{"create.post.js", "createpre.js", "create+pre.js", "create.pre.py", "xcreate.pre.js", "create.pre.js.bak"},
+		},
+		{
+			name:     "default function template",
+			template: DEFAULT_FUNCTION_TEMPLATE,
+			values:   functionValues("sendMail"),
+			matches:  []string{"sendMail.js", "functions/sendMail.ts"},
+			rejects:  []string{"sendmail.js", "sendMail_pre.js", "sendMail.jsx"},
+		},
+		{
+			name:     "datastore directory",
+			template: "{datastore}/{action}{sep}{type}.{ext}",
+			values:   testActionValues,
+			matches:  []string{"orders/create.pre.js", "orders/create_pre.ts"},
+			rejects:  []string{"customers/create.pre.js", "create.pre.js", "x/orders/create.pre.js"},
+		},
+		{
+			name:     "glob around placeholders",
+			template: "scripts/*/{action}.{type}.{ext}",
+			values:   testActionValues,
+			matches:  []string{"scripts/v1/create.pre.js"},
+			rejects:  []string{"scripts/create.pre.js", "scripts/a/b/create.pre.js"},
+		},
+		{
+			name:     "values are literal",
+			template: "{action}.{ext}",
+			values:   map[string]string{ACTION_PLACEHOLDER: "a.b+c"},
+			matches:  []string{"a.b+c.js"},
+			rejects:  []string{"axb+c.js", "a.bbc.js"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re := compileTemplate(tt.template, tt.values)
+			for _, p := range tt.matches {
+				if !re.MatchString(p) {
+					t.Errorf("%q should match %q (regexp %s)", tt.template, p, re)
+				}
+			}
+			for _, p := range tt.rejects {
+				if re.MatchString(p) {
+					t.Errorf("%q should not match %q (regexp %s)", tt.template, p, re)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		values   map[string]string
+		want     string
+		ok       bool
+	}{
+		{
+			name:     "placeholders are filled in",
+			template: "{datastore}/{action}{sep}{type}.{ext}",
+			values:   testActionValues,
+			want:     "orders/create.pre.js",
+			ok:       true,
+		},
+		{
+			name:     "function",
+			template: "functions/{function}.{ext}",
+			values:   functionValues("sendMail"),
+			want:     "functions/sendMail.js",
+			ok:       true,
+		},
+		{
+			name:     "path is cleaned",
+			template: "./a//{action}.{ext}",
+			values:   testActionValues,
+			want:     "a/create.js",
+			ok:       true,
+		},
+		{
+			name:     "** has no single path",
+			template: DEFAULT_ACTION_TEMPLATE,
+			values:   testActionValues,
+			ok:       false,
+		},
+		{
+			name:     "* has no single path",
+			template: "*/{action}.{ext}",
+			values:   testActionValues,
+			ok:       false,
+		},
+		{
+			name:     "? has no single path",
+			template: "{action}?.{ext}",
+			values:   testActionValues,
+			ok:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := expandTemplate(tt.template, tt.values)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("expandTemplate(%q) = %q, %v; want %q, %v", tt.template, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTemplateNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		values   map[string]string
+		want     []string
+	}{
+		{
+			name:     "default action template",
+			template: DEFAULT_ACTION_TEMPLATE,
+			values:   testActionValues,
+			want: []string{
+				"create.pre.js", "create.pre.ts",
+				"create_pre.js", "create_pre.ts",
+				"create-pre.js", "create-pre.ts",
+			},
+		},
+		{
+			name:     "only the file name is used",
+			template: "{datastore}/{function}.{ext}",
+			values:   map[string]string{DATASTORE_PLACEHOLDER: "orders", FUNCTION_PLACEHOLDER: "sendMail"},
+			want:     []string{"sendMail.js", "sendMail.ts"},
+		},
+		{
+			name:     "fixed extension",
+			template: "{action}.{type}.js",
+			values:   testActionValues,
+			want:     []string{"create.pre.js"},
+		},
+		{
+			name:     "no placeholders",
+			template: "scripts/main.js",
+			values:   nil,
+			want:     []string{"main.js"},
+		},
+		{
+			name:     "wildcard in the file name",
+			template: "scripts/*.{ext}",
+			values:   testActionValues,
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := templateNames(tt.template, tt.values)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("templateNames(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+// every name given by templateNames should be matched by the compiled template
+func TestTemplateNamesMatchCompiledTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		dir      string // directory the names are checked in
+	}{
+		{DEFAULT_ACTION_TEMPLATE, "a/b/"},
+		{"{datastore}/{action}{sep}{type}.{ext}", "orders/"},
+	}
+	for _, tt := range tests {
+		re := compileTemplate(tt.template, testActionValues)
+		for _, name := range templateNames(tt.template, testActionValues) {
+			if !re.MatchString(tt.dir + name) {
+				t.Errorf("%q should match %q", tt.template, tt.dir+name)
+			}
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		allowed  []string
+		wantErr  bool
+	}{
+		{DEFAULT_ACTION_TEMPLATE, []string{ACTION_PLACEHOLDER, TYPE_PLACEHOLDER, SEP_PLACEHOLDER, EXT_PLACEHOLDER}, false},
+		{"{datastore}/{action}.{type}.js", []string{DATASTORE_PLACEHOLDER, ACTION_PLACEHOLDER, TYPE_PLACEHOLDER}, false},
+		{"functions/main.js", nil, false},
+		{"{function}.{ext}", []string{ACTION_PLACEHOLDER, EXT_PLACEHOLDER}, true},
+		{"{actoin}.js", []string{ACTION_PLACEHOLDER}, true},
+	}
+	for _, tt := range tests {
+		err := ValidateTemplate(tt.template, tt.allowed...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTemplate(%q) error = %v, wantErr %v", tt.template, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package action
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/utils"
+)
+
+// default directory (under the pull root) where function actionscripts are saved
+const FUNCTIONS_DIR = "functions"
+
+// file (under the pull root) that records the hash of each script as it was last pulled.
+// this is how we know if a local file has been modified since it was pulled.
+const PULL_STATE_FILE = ".hxutil-pull.json"
+
+// LocalScriptPath gives the default path (relative to the pull root) where an action's script is saved.
+//
+// Layout: <datastore display id>/<action display id>.<pre|post>.js
+func LocalScriptPath(action Action, scriptType string) string {
+	return filepath.Join(datastoreDir(action), fmt.Sprintf("%s.%s.js", action.DisplayID, scriptType))
+}
+
+// datastoreDir gives the name of the directory for an action's datastore
+func datastoreDir(action Action) string {
+	if action.DatastoreDisplayID == "" {
+		// datastores without a display ID fall back to their ID, so the layout is still deterministic
+		return action.D_ID
+	}
+	return action.DatastoreDisplayID
+}
+
+// pullState maps a script's relative path to the hash of its contents when it was last pulled
+type pullState map[string]string
+
+func loadPullState(absPath string) pullState {
+	state := pullState{}
+	stateBytes, err := os.ReadFile(filepath.Join(absPath, PULL_STATE_FILE))
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(stateBytes, &state); err != nil {
+		utils.Warn("failed to read pull state; all existing files will be treated as locally modified", err.Error())
+		return pullState{}
+	}
+	return state
+}
+
+func (ps pullState) save(absPath string) error {
+	stateBytes, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(absPath, PULL_STATE_FILE), stateBytes, 0644)
+}
+
+func hashScript(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+type pullResult struct {
+	written   []string
+	unchanged []string
+	skipped   []string
+	failed    []string
+}
+
+// writeScript saves a script to the local tree, unless the local copy has been modified since the last pull.
+func (pr *pullResult) writeScript(absPath, relPath, script string, state pullState, force bool) {
+	fullPath := filepath.Join(absPath, relPath)
+	script = strings.TrimSpace(script) + "\n"
+	remoteHash := hashScript(script)
+
+	localBytes, err := os.ReadFile(fullPath)
+	if err == nil {
+		localHash := hashScript(string(localBytes))
+		if localHash == remoteHash {
+			state[relPath] = remoteHash
+			pr.unchanged = append(pr.unchanged, relPath)
+			return
+		}
+		// the local file differs from remote; only overwrite it if it hasn't been changed since the last pull
+		if lastPulled, exists := state[relPath]; (!exists || lastPulled != localHash) && !force {
+			utils.Warn("local file has been modified; skipping", relPath)
+			pr.skipped = append(pr.skipped, relPath)
+			return
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		utils.Error("failed to read local file: "+relPath, err.Error())
+		pr.failed = append(pr.failed, relPath)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		utils.Error("failed to create directory for "+relPath, err.Error())
+		pr.failed = append(pr.failed, relPath)
+		return
+	}
+	if err := os.WriteFile(fullPath, []byte(script), 0644); err != nil {
+		utils.Error("failed to write "+relPath, err.Error())
+		pr.failed = append(pr.failed, relPath)
+		return
+	}
+	state[relPath] = remoteHash
+	pr.written = append(pr.written, relPath)
+}
+
+// PullActionScripts downloads all actionscripts and function scripts for a project into the tree.
+// Local files that have been modified since they were last pulled are not overwritten, unless force is set.
+//
+// Scripts are downloaded concurrently. If ctx is cancelled, the scripts that haven't been downloaded yet are reported as failed.
+// Scripts that fail to be pulled are shown in the summary, and an error giving how many failed is returned.
+func PullActionScripts(ctx context.Context, tree LocalTree, p_id string, userEmail string, force bool) error {
+	project, client, err := selectProjectAndLogin(ctx, p_id, userEmail)
+	if err != nil {
+		return err
+	}
+	absPath := tree.Root
+
+	state := loadPullState(absPath)
+	result := pullResult{}
+
+	// datastore actionscripts
+	actions, err := GetProjectActions(ctx, utils.Stdout(), client, project.P_ID)
+	if err != nil {
+		return err
+	}
+	warnDuplicateScripts(utils.Stdout(), tree, actions, nil)
+	refs := scriptRefs(actions)
+	downloads := DownloadActionScripts(ctx, client, refs)
+	for i, ref := range refs {
+		relPath := tree.ActionScriptPath(ref.Action, ref.ScriptType)
+		actionscript, err := downloads[i].Value, downloads[i].Err
+		if err != nil {
+			if ctx.Err() == nil {
+				utils.Error("error occurred while fetching actionscript: "+relPath, err.Error())
+			}
+			result.failed = append(result.failed, relPath)
+			continue
+		}
+		if actionscript == "" {
+			continue
+		}
+		result.writeScript(absPath, relPath, actionscript, state, force)
+	}
+
+	// function actionscripts
+	if ctx.Err() != nil {
+		utils.Hint("(interrupted; function scripts were not pulled)")
+	} else if getFunctionsBytes, err := client.GetApi(ctx, hexaclient.UN_GetFunctionActionScriptAPI.URI, map[string]string{
+		"p_id": project.P_ID,
+	}); err != nil {
+		utils.Error("failed to get functions for project", err.Error())
+		result.failed = append(result.failed, "[FUNCTIONS]: "+err.Error())
+	} else {
+		var functions hexaclient.UN_GetFunctionActionScriptResponse
+		if err := json.Unmarshal(getFunctionsBytes, &functions); err != nil {
+			utils.Error("failed to unmarshal functions response", err.Error())
+			result.failed = append(result.failed, "[FUNCTIONS]: "+err.Error())
+		}
+		warnDuplicateScripts(utils.Stdout(), tree, nil, functions)
+		for _, function := range functions {
+			if strings.TrimSpace(function.Pre.Script) == "" {
+				continue
+			}
+			result.writeScript(absPath, tree.FunctionScriptPath(function.FunctionID, function.DisplayID), function.Pre.Script, state, force)
+		}
+	}
+
+	if err := state.save(absPath); err != nil {
+		utils.Error("failed to save pull state", err.Error())
+	}
+
+	fmt.Println("\nSUMMARY\n=======")
+	for _, relPath := range result.written {
+		utils.ColorSuccess.Println("PULLED:", relPath)
+	}
+	for _, relPath := range result.skipped {
+		utils.ColorWarn.Println("SKIPPED (locally modified):", relPath)
+	}
+	for _, relPath := range result.failed {
+		utils.ColorError.Println("FAILED:", relPath)
+	}
+	fmt.Println("\nPulled:", len(result.written))
+	fmt.Println("Unchanged:", len(result.unchanged))
+	fmt.Println("Skipped:", len(result.skipped))
+	fmt.Println("Failed:", len(result.failed))
+	if len(result.skipped) > 0 {
+		utils.Hint("(use --force to overwrite locally modified files)")
+	}
+	fmt.Println("=======\n ")
+	// an interrupted pull is reported by the caller
+	if len(result.failed) > 0 && ctx.Err() == nil {
+		return fmt.Errorf("failed to pull %v script(s)", len(result.failed))
+	}
+	return nil
+}
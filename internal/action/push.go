@@ -0,0 +1,248 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/utils"
+)
+
+// pushCandidate is a local script that differs from its remote version, and can be pushed
+type pushCandidate struct {
+	label  string // how the script is shown in logs and the summary
+	script string
+	upload func(script string) error
+}
+
+type pushResult struct {
+	pushed  []string
+	skipped []string
+	failed  []string
+}
+
+// PushActionScripts uploads local actionscripts and function scripts that differ from the versions saved in Hexabase.
+// Local scripts are found the same way as "action diff" finds them.
+//
+// If dryRun is set, the differences are shown but nothing is uploaded. If skipConfirm is set, the user isn't asked before uploading.
+// Remote scripts are downloaded concurrently. If ctx is cancelled, nothing more is uploaded.
+// Scripts that fail to push are shown in the summary, and an error giving how many failed is returned.
+func PushActionScripts(ctx context.Context, tree LocalTree, p_id string, userEmail string, dryRun bool, skipConfirm bool) error {
+	project, client, err := selectProjectAndLogin(ctx, p_id, userEmail)
+	if err != nil {
+		return err
+	}
+
+	result := pushResult{}
+	candidates := make([]pushCandidate, 0)
+
+	// datastore actionscripts
+	actions, err := GetProjectActions(ctx, utils.Stdout(), client, project.P_ID)
+	if err != nil {
+		return err
+	}
+	warnDuplicateScripts(utils.Stdout(), tree, actions, nil)
+
+	// only scripts with a local file need to be downloaded
+	refs := make([]ScriptRef, 0)
+	localPaths := make([]string, 0)
+	for _, ref := range scriptRefs(actions) {
+		label := ref.label()
+
+		localPath, err := tree.FindActionScript(ref.Action, ref.ScriptType)
+		if err != nil {
+			utils.Error("failed to find local script", err.Error())
+			result.failed = append(result.failed, label+": "+err.Error())
+			continue
+		}
+		if localPath == "" {
+			// nothing to push
+			continue
+		}
+		if !pushable(localPath) {
+			result.skipped = append(result.skipped, label+": not a .js file")
+			continue
+		}
+		refs = append(refs, ref)
+		localPaths = append(localPaths, localPath)
+	}
+
+	downloads := DownloadActionScripts(ctx, client, refs)
+	for i, ref := range refs {
+		label, localPath := ref.label(), localPaths[i]
+
+		remoteScript, err := downloads[i].Value, downloads[i].Err
+		if err != nil {
+			if ctx.Err() == nil {
+				utils.Error("error occurred while fetching actionscript", err.Error())
+			}
+			result.failed = append(result.failed, label+": "+err.Error())
+			continue
+		}
+
+		candidate, changed, err := comparePushCandidate(label, localPath, remoteScript, func(script string) error {
+			return uploadActionScript(ctx, client, ref.Action.ID, ref.ScriptType, filepath.Base(localPath), script)
+		})
+		if err != nil {
+			result.failed = append(result.failed, label+": "+err.Error())
+			continue
+		}
+		if !changed {
+			result.skipped = append(result.skipped, label+": unchanged")
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	// function actionscripts
+	getFunctionsBytes, err := client.GetApi(ctx, hexaclient.UN_GetFunctionActionScriptAPI.URI, map[string]string{
+		"p_id": project.P_ID,
+	})
+	var functions hexaclient.UN_GetFunctionActionScriptResponse
+	if err != nil {
+		utils.Error("failed to get functions for project", err.Error())
+		result.failed = append(result.failed, "[FUNCTIONS]: "+err.Error())
+	} else if err := json.Unmarshal(getFunctionsBytes, &functions); err != nil {
+		utils.Error("failed to unmarshal functions response", err.Error())
+		result.failed = append(result.failed, "[FUNCTIONS]: "+err.Error())
+	} else {
+		warnDuplicateScripts(utils.Stdout(), tree, nil, functions)
+		for _, function := range functions {
+			label := function.DisplayID + " [FUNCTION]"
+
+			localPath, err := tree.FindFunctionScript(function.FunctionID, function.DisplayID)
+			if err != nil {
+				utils.Error("failed to find local script", err.Error())
+				result.failed = append(result.failed, label+": "+err.Error())
+				continue
+			}
+			if localPath == "" {
+				continue
+			}
+			if !pushable(localPath) {
+				result.skipped = append(result.skipped, label+": not a .js file")
+				continue
+			}
+
+			payload := hexaclient.UN_UpdateFunctionActionScriptPayload{
+				ID:         function.ID,
+				FunctionID: function.FunctionID,
+				PID:        function.PID,
+			}
+			payload.Pre.TimeoutSec = function.Pre.TimeoutSec
+			candidate, changed, err := comparePushCandidate(label, localPath, strings.TrimSpace(function.Pre.Script), func(script string) error {
+				payload.Pre.Script = script
+				return uploadFunctionScript(ctx, client, payload)
+			})
+			if err != nil {
+				result.failed = append(result.failed, label+": "+err.Error())
+				continue
+			}
+			if !changed {
+				result.skipped = append(result.skipped, label+": unchanged")
+				continue
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	if len(candidates) == 0 {
+		utils.Hint("(No local changes to push)")
+	} else if dryRun {
+		utils.Hint(fmt.Sprintf("(Dry run: %v scripts would be pushed)", len(candidates)))
+		for _, candidate := range candidates {
+			result.skipped = append(result.skipped, candidate.label+": dry run")
+		}
+	} else if !skipConfirm && !utils.YesOrNo(fmt.Sprintf("Push %v scripts to Hexabase?", len(candidates))) {
+		for _, candidate := range candidates {
+			result.skipped = append(result.skipped, candidate.label+": not confirmed")
+		}
+	} else {
+		for _, candidate := range candidates {
+			if ctx.Err() != nil {
+				result.skipped = append(result.skipped, candidate.label+": interrupted")
+				continue
+			}
+			if err := candidate.upload(candidate.script); err != nil {
+				utils.Error("failed to push "+candidate.label, err.Error())
+				result.failed = append(result.failed, candidate.label+": "+err.Error())
+				continue
+			}
+			result.pushed = append(result.pushed, candidate.label)
+		}
+	}
+
+	fmt.Println("\nSUMMARY\n=======")
+	for _, label := range result.pushed {
+		utils.ColorSuccess.Println("PUSHED:", label)
+	}
+	for _, label := range result.skipped {
+		utils.ColorHint.Println("SKIPPED:", label)
+	}
+	for _, label := range result.failed {
+		utils.ColorError.Println("FAILED:", label)
+	}
+	fmt.Println("\nPushed:", len(result.pushed))
+	fmt.Println("Skipped:", len(result.skipped))
+	fmt.Println("Failed:", len(result.failed))
+	fmt.Println("=======\n ")
+	// an interrupted push is reported by the caller
+	if len(result.failed) > 0 && ctx.Err() == nil {
+		return fmt.Errorf("failed to push %v script(s)", len(result.failed))
+	}
+	return nil
+}
+
+// pushable is false for sources (such as TypeScript) that hexabase can't run as they are.
+// These aren't pushed, and aren't diffed either, since hexabase only has their compiled output.
+func pushable(localPath string) bool {
+	return filepath.Ext(localPath) == ".js"
+}
+
+// comparePushCandidate reads a local script and shows how it differs from the remote script.
+// changed is false if there's nothing to push.
+func comparePushCandidate(label, localPath, remoteScript string, upload func(script string) error) (pushCandidate, bool, error) {
+	localBytes, err := os.ReadFile(localPath)
+	if err != nil {
+		return pushCandidate{}, false, err
+	}
+	localScript := string(localBytes)
+	if strings.TrimSpace(localScript) == "" {
+		return pushCandidate{}, false, nil
+	}
+
+	diff := utils.GetDiff(remoteScript, localScript)
+	if diff == "" {
+		return pushCandidate{}, false, nil
+	}
+	fmt.Println("\n===")
+	fmt.Println(label, utils.ColorHint.Sprint(localPath)+"\n")
+	fmt.Println(diff)
+	fmt.Println("===")
+
+	return pushCandidate{
+		label:  label,
+		script: localScript,
+		upload: upload,
+	}, true, nil
+}
+
+func uploadActionScript(ctx context.Context, client *hexaclient.Client, actionID, scriptType, fileName, script string) error {
+	_, err := client.PostMultipartApi(ctx, fmt.Sprintf(hexaclient.UploadActionScriptAPI.URI, actionID), map[string]string{
+		"script_type": scriptType,
+	}, "filename", fileName, []byte(script))
+	return err
+}
+
+func uploadFunctionScript(ctx context.Context, client *hexaclient.Client, payload hexaclient.UN_UpdateFunctionActionScriptPayload) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = client.PostApi(ctx, hexaclient.UN_UpdateFunctionActionScriptAPI.URI, payloadBytes)
+	return err
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -12,31 +13,89 @@ import (
 	"github.com/bwebb-hx/hxutil/internal/utils"
 )
 
-func configDir() string {
+func configDir() (string, error) {
 	homePath, err := os.UserHomeDir()
 	if err != nil {
-		utils.Fatal("failed to get home directory", err.Error())
+		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
 	path := filepath.Join(homePath, ".config", "hxutil")
-	return path
+	return path, nil
 }
 
-func ConfigFilePath() string {
-	return filepath.Join(configDir(), "config.json")
+func ConfigFilePath() (string, error) {
+	return DataFilePath("config.json")
 }
 
+// DataFilePath gives the path of a file that hxutil stores data in, under the config directory.
+func DataFilePath(name string) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// EnsureConfigDir creates the config directory if needed. It holds secrets, so it's kept private to the user,
+// including when it was created by an older version with looser permissions.
 func EnsureConfigDir() error {
-	path := configDir()
-	_, err := os.Stat(path)
+	path, err := configDir()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(path)
 	if os.IsNotExist(err) {
-		return os.MkdirAll(path, 0777)
+		return os.MkdirAll(path, 0700)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0700); err != nil {
+		return fmt.Errorf("failed to restrict permissions of config directory: %w", err)
 	}
 	return nil
 }
 
 type User struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email string `json:"email"`
+
+	// Deprecated: passwords are kept in the secrets backend (see GetPassword).
+	// this is only set by old configs, and is moved to the secrets backend the next time the config is saved.
+	Password string `json:"password,omitempty"`
+
+	env string // the environment the user is registered in. empty for the default environment.
+}
+
+// Login logs the client in to hexabase as this user. A cached token is used when possible, so the password isn't needed.
+func (u User) Login(ctx context.Context, client *hx.Client) error {
+	if client.UseCachedToken(u.Email) {
+		return nil
+	}
+	password, err := u.password()
+	if err != nil {
+		return fmt.Errorf("failed to get password for %s: %w", u.Email, err)
+	}
+	if _, err := client.LoginFresh(ctx, u.Email, password); err != nil {
+		return fmt.Errorf("failed to login as %s: %w", u.Email, err)
+	}
+	return nil
+}
+
+// TestLogin logs the client in as this user with the stored password, ignoring any cached token.
+func (u User) TestLogin(ctx context.Context, client *hx.Client) error {
+	password, err := u.password()
+	if err != nil {
+		return err
+	}
+	_, err = client.LoginFresh(ctx, u.Email, password)
+	return err
+}
+
+// password gives the user's stored password. users of old configs that haven't been migrated yet still have it in the config.
+func (u User) password() (string, error) {
+	if u.Password != "" {
+		return u.Password, nil
+	}
+	return getPassword(u.env, u.Email)
 }
 
 type Project struct {
@@ -56,38 +115,100 @@ func (p Project) String() string {
 }
 
 type Config struct {
-	LastLoginUser   string    `json:"last_login_user"`   // email used last time for hxutil
-	LastUsedProject string    `json:"last_used_project"` // project used last time for hxutil
-	Users           []User    `json:"users"`
-	Projects        []Project `json:"projects"`
+	// the selected environment. the default environment is kept at the top level of the config file,
+	// so configs from before environments existed still work.
+	Environment
+
+	Environments map[string]Environment `json:"environments,omitempty"` // named environments, besides the default one
+	Vars         map[string]string      `json:"vars,omitempty"`         // user-defined variables, used as placeholders in "api call"
+
+	envName    string      // name of the selected environment
+	defaultEnv Environment // the default environment, kept aside while another one is selected
 }
 
-func (c *Config) AddProject() *Project {
+func (c *Config) AddProject(ctx context.Context, client *hx.Client) (*Project, error) {
 	// get pid from user
-	p_id := utils.GetInput("Project ID")
+	p_id, err := utils.GetInput("Project ID")
+	if err != nil {
+		return nil, err
+	}
 
 	// determine the user credentials to login with
-	c.SelectUserAndLogin("")
+	if err := c.SelectUserAndLogin(ctx, client, ""); err != nil {
+		return nil, err
+	}
+
+	project, err := c.RegisterProject(ctx, client, p_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add project: %w", err)
+	}
+	return project, nil
+}
+
+// RegisterProject adds a project to config, with its details looked up from hexabase. The client must already be logged in.
+func (c *Config) RegisterProject(ctx context.Context, client *hx.Client, p_id string) (*Project, error) {
+	if c.GetProject(p_id) != nil {
+		return nil, fmt.Errorf("project already registered: %s", p_id)
+	}
+
+	project, err := fetchProject(ctx, client, p_id)
+	if err != nil {
+		return nil, err
+	}
+	c.Projects = append(c.Projects, project)
+	if err := c.Save(); err != nil {
+		return nil, err
+	}
+
+	return &c.Projects[len(c.Projects)-1], nil
+}
+
+// RefreshProject updates the details of a registered project (such as its display ID) from hexabase.
+// The client must already be logged in.
+func (c *Config) RefreshProject(ctx context.Context, client *hx.Client, idOrDisplayID string) (*Project, error) {
+	project := c.GetProject(idOrDisplayID)
+	if project == nil {
+		return nil, fmt.Errorf("project not registered: %s", idOrDisplayID)
+	}
+	refreshed, err := fetchProject(ctx, client, project.P_ID)
+	if err != nil {
+		return nil, err
+	}
+	refreshed.LastLoginUser = project.LastLoginUser
+	*project = refreshed
+	if err := c.Save(); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
 
+// fetchProject looks up the details of a project from hexabase
+func fetchProject(ctx context.Context, client *hx.Client, p_id string) (Project, error) {
 	// get project details
-	bytes, err := hx.GetApi(hx.UN_GetProjectSettingsAPI.URI, map[string]string{"p_id": p_id})
+	bytes, err := client.GetApi(ctx, hx.UN_GetProjectSettingsAPI.URI, map[string]string{"p_id": p_id})
+	if hx.IsNotFound(err) || hx.IsUnauthorized(err) {
+		return Project{}, fmt.Errorf("project not found, or not accessible by the logged in user: %s: %w", p_id, err)
+	}
 	if err != nil {
-		utils.Fatal("failed to get project details", err.Error())
+		return Project{}, fmt.Errorf("failed to get project details: %w", err)
 	}
 	var resp hx.UN_GetProjectSettingsResponse
 	err = json.Unmarshal(bytes, &resp)
 	if err != nil {
-		utils.Fatal("failed to unmarshal project details response", err.Error())
+		return Project{}, fmt.Errorf("failed to unmarshal project details response: %w", err)
+	}
+	if resp.PID == "" {
+		return Project{}, fmt.Errorf("project not found, or not accessible by the logged in user: %s", p_id)
 	}
 
 	// get workspace name
-	workspaceBytes, err := hx.GetApi(hx.GetWorkspacesAPI.URI, nil)
+	workspaceBytes, err := client.GetApi(ctx, hx.GetWorkspacesAPI.URI, nil)
 	if err != nil {
-		utils.Fatal("failed to get workspaces", err.Error())
+		return Project{}, fmt.Errorf("failed to get workspaces: %w", err)
 	}
 	var workspaceResp hx.GetWorkspacesResponse
 	if err = json.Unmarshal(workspaceBytes, &workspaceResp); err != nil {
-		utils.Fatal("failed to unmarshal workspaces response", err.Error())
+		return Project{}, fmt.Errorf("failed to unmarshal workspaces response: %w", err)
 	}
 	workspaceName := ""
 	for _, workspace := range workspaceResp.Workspaces {
@@ -97,44 +218,45 @@ func (c *Config) AddProject() *Project {
 		}
 	}
 
-	project := Project{
+	return Project{
 		P_ID:          p_id,
 		DisplayID:     resp.DisplayID,
 		WorkspaceID:   resp.WorkspaceID,
 		WorkspaceName: workspaceName,
-	}
-	c.Projects = append(c.Projects, project)
-	c.Save()
-
-	return &project
+	}, nil
 }
 
-func (c *Config) SelectProject() *Project {
+// SelectProject prompts for a registered project, or a new one to register.
+// The prompts go to stderr, like all prompts (see utils.GetInput).
+func (c *Config) SelectProject(ctx context.Context, client *hx.Client) (*Project, error) {
 	if len(c.Projects) > 0 {
-		fmt.Println("Existing projects:")
+		fmt.Fprintln(os.Stderr, "Existing projects:")
 		for i, project := range c.Projects {
-			fmt.Printf("%v) %s\n", i+1, project)
+			fmt.Fprintf(os.Stderr, "%v) %s\n", i+1, project)
+		}
+		input, err := utils.GetInput("Choose project (or \"new\")")
+		if err != nil {
+			return nil, err
 		}
-		input := utils.GetInput("Choose project (or \"new\")")
 
 		if input == "new" {
-			return c.AddProject()
+			return c.AddProject(ctx, client)
 		}
 		index, err := strconv.Atoi(input)
 		if err != nil {
-			utils.Fatal("failed to parse index number", err.Error())
+			return nil, fmt.Errorf("failed to parse index number: %w", err)
 		}
 		for i, project := range c.Projects {
 			if i+1 == index {
-				return &project
+				return &project, nil
 			}
 		}
 	}
 
-	return c.AddProject()
+	return c.AddProject(ctx, client)
 }
 
-func (c *Config) SelectUserAndLogin(p_id string) {
+func (c *Config) SelectUserAndLogin(ctx context.Context, client *hx.Client, p_id string) error {
 	// determine if a "last login user" is applicable
 	lastLoginUser := ""
 	if p_id != "" {
@@ -146,147 +268,319 @@ func (c *Config) SelectUserAndLogin(p_id string) {
 			}
 		}
 		if lastLoginUser == "" {
-			utils.Hint("(No login user found for given project)")
+			utils.HintTo(os.Stderr, "(No login user found for given project)")
 		} else {
-			fmt.Println("Last login user for this project:", lastLoginUser)
+			fmt.Fprintln(os.Stderr, "Last login user for this project:", lastLoginUser)
 		}
 	} else {
 		// if no p_id is passed, check the last logged in user overall
 		if c.LastLoginUser != "" {
 			lastLoginUser = c.LastLoginUser
-			fmt.Println("Last login user:", lastLoginUser)
+			fmt.Fprintln(os.Stderr, "Last login user:", lastLoginUser)
 		}
 	}
 
+	// no need to ask if the last login user still has a valid token
+	if lastLoginUser != "" && client.UseCachedToken(lastLoginUser) {
+		utils.HintTo(os.Stderr, "(using cached login)")
+		return nil
+	}
+
 	// if a last login user is found, try to use that
 	if lastLoginUser != "" {
 		if utils.YesOrNo("Login with this user?") {
-			password := ""
-			for _, user := range c.Users {
-				if user.Email == lastLoginUser {
-					password = user.Password
-					break
-				}
-			}
-			if password == "" {
-				utils.Error("failed to find registered user in config", "")
+			user := c.GetUser(lastLoginUser)
+			if user == nil {
+				utils.ErrorTo(os.Stderr, "failed to find registered user in config", "")
 			} else {
-				hx.Login(lastLoginUser, password)
-				return
+				return user.Login(ctx, client)
 			}
 		}
 	}
 
 	// choose an existing user or register a new one
 	if len(c.Users) == 0 {
-		utils.Hint("(no existing users found)")
-		user := c.AddNewUser()
+		utils.HintTo(os.Stderr, "(no existing users found)")
+		user, err := c.AddNewUser(ctx, client)
+		if err != nil {
+			return err
+		}
 		if p_id != "" {
-			c.SetProjectLastUser(p_id, user.Email)
+			return c.SetProjectLastUser(p_id, user.Email)
 		}
-		return
+		return nil
 	}
 	for i, user := range c.Users {
-		fmt.Printf("%v) %s", i+1, user.Email)
+		fmt.Fprintf(os.Stderr, "%v) %s", i+1, user.Email)
+	}
+	input, err := utils.GetInput("Choose user (or \"new\")")
+	if err != nil {
+		return err
 	}
-	input := utils.GetInput("Choose user (or \"new\")")
 	if strings.ToLower(input) == "new" {
-		user := c.AddNewUser()
+		user, err := c.AddNewUser(ctx, client)
+		if err != nil {
+			return err
+		}
 		if p_id != "" {
-			c.SetProjectLastUser(p_id, user.Email)
+			return c.SetProjectLastUser(p_id, user.Email)
 		}
-		return
+		return nil
 	}
 
 	// find the corresponding user
 	index, err := strconv.Atoi(input)
 	if err != nil {
-		utils.Error("failed to parse input", err.Error())
+		return fmt.Errorf("failed to parse input: %w", err)
 	}
 	for i, user := range c.Users {
 		if i+1 == index {
-			hx.Login(user.Email, user.Password)
+			if err := user.Login(ctx, client); err != nil {
+				return err
+			}
 			if p_id != "" {
-				c.SetProjectLastUser(p_id, user.Email)
+				return c.SetProjectLastUser(p_id, user.Email)
 			}
-			return
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to login: entered index invalid: %s", input)
+}
+
+// GetProject returns the registered project with the given project ID or display ID, or nil if there isn't one.
+func (c *Config) GetProject(idOrDisplayID string) *Project {
+	for i, project := range c.Projects {
+		if project.P_ID == idOrDisplayID || project.DisplayID == idOrDisplayID {
+			return &c.Projects[i]
+		}
+	}
+	return nil
+}
+
+// GetUser returns the registered user with the given email, or nil if there isn't one.
+func (c *Config) GetUser(email string) *User {
+	for i, user := range c.Users {
+		if user.Email == email {
+			return &c.Users[i]
 		}
 	}
-	utils.Error("failed to login", "entered index invalid")
+	return nil
 }
 
-func (c *Config) SetProjectLastUser(p_id, userEmail string) {
+// SetProjectLastUser remembers the user last used for a registered project, and saves the config.
+// An unregistered project is only reported, since there's nothing to remember the user for.
+func (c *Config) SetProjectLastUser(p_id, userEmail string) error {
 	for i, project := range c.Projects {
 		if project.P_ID == p_id {
 			c.Projects[i].LastLoginUser = userEmail
-			c.Save()
-			return
+			return c.Save()
 		}
 	}
 	utils.Error("failed to set last login user for project", "matching p_id not found")
+	return nil
+}
+
+func (c *Config) AddNewUser(ctx context.Context, client *hx.Client) (*User, error) {
+	email, err := utils.GetInput("User email")
+	if err != nil {
+		return nil, err
+	}
+	password, err := utils.GetSecretInput("Password")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password: %w", err)
+	}
+
+	user, err := c.AddUser(ctx, client, email, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add user: %w", err)
+	}
+	return user, nil
 }
 
-func (c *Config) AddNewUser() *User {
-	email := utils.GetInput("User email")
-	password := utils.GetInput("Password")
+// AddUser registers a user in config, after confirming the credentials by logging the client in.
+func (c *Config) AddUser(ctx context.Context, client *hx.Client, email, password string) (*User, error) {
+	if c.GetUser(email) != nil {
+		return nil, fmt.Errorf("user already registered: %s", email)
+	}
 
 	// attempt login
-	hx.Login(email, password)
+	if _, err := client.LoginFresh(ctx, email, password); err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+
+	// the password is kept separately from the config
+	if err := secretBackend.Set(secretKey(c.envName, email), password); err != nil {
+		return nil, fmt.Errorf("failed to store password: %w", err)
+	}
 
 	// add to config
 	user := User{
-		Email:    email,
-		Password: password,
+		Email: email,
+		env:   c.envName,
 	}
 	c.Users = append(c.Users, user)
 	c.LastLoginUser = email
-	c.Save()
+	if err := c.Save(); err != nil {
+		return nil, err
+	}
 
-	return &user
+	return &c.Users[len(c.Users)-1], nil
 }
 
-func (c Config) Save() {
-	path := ConfigFilePath()
+// Save writes the config to the config file
+func (c Config) Save() error {
+	path, err := ConfigFilePath()
+	if err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	// the default environment goes back at the top level
+	if c.envName != DEFAULT_ENV {
+		c.Environments[c.envName] = c.Environment
+		c.Environment = c.defaultEnv
+	}
+
+	// plaintext passwords from old configs are moved out before they'd be written again
+	if migrated, err := c.migratePasswords(); err != nil {
+		utils.WarnTo(utils.Stderr(), "failed to move plaintext passwords out of config", err.Error())
+	} else if migrated > 0 {
+		utils.InfoTo(utils.Stderr(), "Config migrated", fmt.Sprintf("moved %v password(s) from config.json into the encrypted secrets file", migrated))
+	}
 
 	bytes, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
-		utils.Error("failed to save config", err.Error())
-		return
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if err := writeFileAtomic(path, bytes, 0600); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes to a temp file first and then renames it, so a half-written file is never left behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// migratePasswords moves plaintext passwords from old configs into the secrets backend, giving how many were moved.
+// If that fails, the passwords that weren't moved are left as they are so nothing is lost.
+// The default environment must be at the top level (as it is in the config file).
+func (c *Config) migratePasswords() (int, error) {
+	migrated := 0
+	migrateUsers := func(envName string, users []User) error {
+		for i, user := range users {
+			if user.Password == "" {
+				continue
+			}
+			if err := secretBackend.Set(secretKey(envName, user.Email), user.Password); err != nil {
+				return err
+			}
+			users[i].Password = ""
+			migrated++
+		}
+		return nil
+	}
+
+	if err := migrateUsers(DEFAULT_ENV, c.Users); err != nil {
+		return migrated, err
+	}
+	for name, env := range c.Environments {
+		if err := migrateUsers(name, env.Users); err != nil {
+			return migrated, err
+		}
+	}
+	return migrated, nil
+}
+
+// MigratePasswords moves plaintext passwords from old configs into the secrets backend, and saves the config.
+// This is also done whenever the config is saved; it isn't done when the config is read, so reading it never prompts for the secrets passphrase.
+func (c *Config) MigratePasswords() error {
+	users := append(append([]User{}, c.Users...), c.defaultEnv.Users...)
+	for _, env := range c.Environments {
+		users = append(users, env.Users...)
+	}
+	for _, user := range users {
+		if user.Password != "" {
+			return c.Save()
+		}
 	}
+	return nil
+}
 
-	err = os.WriteFile(path, bytes, 0777)
+// GetConfig loads the config, with the selected environment (see UseEnvironment) at the top level.
+func GetConfig() (*Config, error) {
+	return GetEnvironmentConfig(selectedEnv)
+}
+
+// GetEnvironmentConfig loads the config, with the given environment at the top level. An empty name gives the default environment.
+// Changes saved through one loaded config aren't seen by others that were loaded before, so load it again after saving.
+func GetEnvironmentConfig(name string) (*Config, error) {
+	if name == "" {
+		name = DEFAULT_ENV
+	}
+	config, err := readConfig()
 	if err != nil {
-		utils.Error("failed to save config", err.Error())
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if name != DEFAULT_ENV {
+		env, exists := config.Environments[name]
+		if !exists {
+			return nil, fmt.Errorf("environment not found: %s (available: %s)", name, strings.Join(config.EnvironmentNames(), ", "))
+		}
+		for i := range env.Users {
+			env.Users[i].env = name
+		}
+		config.defaultEnv = config.Environment
+		config.Environment = env
+		config.envName = name
 	}
+	return config, nil
 }
 
-func GetConfig() *Config {
+// readConfig reads the config file as it is, with the default environment selected.
+func readConfig() (*Config, error) {
 	if err := EnsureConfigDir(); err != nil {
-		utils.Fatal("error while ensuring config directory", err.Error())
+		return nil, fmt.Errorf("error while ensuring config directory: %w", err)
 	}
-	path := ConfigFilePath()
+	path, err := ConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	config := Config{envName: DEFAULT_ENV}
 
 	// if config doesn't exist yet, return an empty struct
-	_, err := os.Stat(path)
+	_, err = os.Stat(path)
 	if os.IsNotExist(err) {
-		return &Config{}
+		return &config, nil
 	}
 	if err != nil {
-		utils.Fatal("error while checking for config file", err.Error())
+		return nil, fmt.Errorf("error while checking for config file: %w", err)
 	}
 
 	// read the existing config file
 	configBytes, err := os.ReadFile(path)
 	if err != nil {
-		utils.Error("failed to read config", err.Error())
-		return nil
+		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
-
-	var config Config
-	err = json.Unmarshal(configBytes, &config)
-	if err != nil {
-		utils.Error("failed to unmarshal config data", err.Error())
-		return nil
+	if err := json.Unmarshal(configBytes, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config data: %w", err)
 	}
-	return &config
+	return &config, nil
 }
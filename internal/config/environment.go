@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	hx "github.com/bwebb-hx/hxutil/internal/hexaClient"
+)
+
+const (
+	// name of the environment kept at the top level of the config file
+	DEFAULT_ENV = "default"
+	// env var used to select an environment, when --env isn't given
+	ENV_VAR = "HXUTIL_ENV"
+)
+
+// Environment is a hexabase deployment (such as prod, staging or dev), along with the users and projects registered for it.
+type Environment struct {
+	ApiBaseURL     string `json:"api_base_url,omitempty"`     // defaults to https://api.hexabase.com
+	ConsoleBaseURL string `json:"console_base_url,omitempty"` // defaults to https://app.hexabase.com (used by unofficial APIs)
+
+	LastLoginUser   string    `json:"last_login_user"`   // email used last time for hxutil
+	LastUsedProject string    `json:"last_used_project"` // project used last time for hxutil
+	Users           []User    `json:"users"`
+	Projects        []Project `json:"projects"`
+}
+
+// the environment used by GetConfig
+var selectedEnv = DEFAULT_ENV
+
+// SelectedEnvironment gives the name of the environment in use.
+func SelectedEnvironment() string {
+	return selectedEnv
+}
+
+// UseEnvironment selects the environment that GetConfig gives. An empty name selects the default environment.
+func UseEnvironment(name string) error {
+	if name == "" {
+		name = DEFAULT_ENV
+	}
+	c, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	if name != DEFAULT_ENV {
+		if _, exists := c.Environments[name]; !exists {
+			return fmt.Errorf("environment not found: %s (available: %s)", name, strings.Join(c.EnvironmentNames(), ", "))
+		}
+	}
+
+	selectedEnv = name
+	return nil
+}
+
+// NewClient gives an API client for the environment, which isn't logged in yet.
+func (e Environment) NewClient() *hx.Client {
+	return hx.NewClient(e.ApiBaseURL, e.ConsoleBaseURL)
+}
+
+// EnvironmentNames lists all environments, starting with the default one.
+func (c Config) EnvironmentNames() []string {
+	names := make([]string, 0, len(c.Environments))
+	for name := range c.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DEFAULT_ENV}, names...)
+}
+
+// RemoveEnvironment removes a named environment, along with its users and projects.
+// The default environment, and the one in use, can't be removed.
+func (c *Config) RemoveEnvironment(name string) error {
+	if name == DEFAULT_ENV || name == selectedEnv {
+		return fmt.Errorf("can't remove the %s environment", name)
+	}
+	if _, exists := c.Environments[name]; !exists {
+		return fmt.Errorf("environment not found: %s", name)
+	}
+	delete(c.Environments, name)
+	return c.Save()
+}
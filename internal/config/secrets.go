@@ -0,0 +1,282 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bwebb-hx/hxutil/internal/utils"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// file (in the config directory) where user passwords are stored, encrypted
+	SECRETS_FILE = "secrets.json"
+
+	// base64 encoded 32 byte key, used as is to encrypt secrets
+	SECRET_KEY_ENV = "HXUTIL_SECRET_KEY"
+	// passphrase to derive the key from. prompted for if neither env var is set.
+	PASSPHRASE_ENV = "HXUTIL_PASSPHRASE"
+)
+
+// SecretBackend stores user passwords, by email.
+// The default is an encrypted file in the config directory, but another backend (such as an OS keyring) can be set instead.
+type SecretBackend interface {
+	Get(email string) (string, bool, error)
+	Set(email, password string) error
+	Delete(email string) error
+}
+
+var secretBackend SecretBackend = &encryptedFileBackend{}
+
+// SetSecretBackend changes where user passwords are stored.
+func SetSecretBackend(backend SecretBackend) {
+	secretBackend = backend
+}
+
+// GetPassword gives the stored password of a user of the selected environment.
+func GetPassword(email string) (string, error) {
+	return getPassword(selectedEnv, email)
+}
+
+func getPassword(envName, email string) (string, error) {
+	password, exists, err := secretBackend.Get(secretKey(envName, email))
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("no password stored for %s", email)
+	}
+	return password, nil
+}
+
+// secretKey is what a user's password is stored under. users of different environments are kept apart,
+// since the same email may have a different password in each.
+func secretKey(envName, email string) string {
+	if envName == DEFAULT_ENV || envName == "" {
+		return email
+	}
+	return envName + "/" + email
+}
+
+// scrypt parameters for deriving a key from a passphrase
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	secretKeyLen = 32 // AES-256
+)
+
+// secretsFile is the on-disk format of the encrypted file.
+// Ciphertext is the JSON of all passwords (by email), encrypted with AES-GCM.
+type secretsFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"` // "scrypt" if the key is derived from a passphrase, or "none" if the key is given directly
+	Salt       string `json:"salt,omitempty"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+type encryptedFileBackend struct {
+	// the key is kept after it's first derived, so the passphrase is only asked for once per run
+	key  []byte
+	salt []byte
+	kdf  string
+}
+
+func secretsFilePath() (string, error) {
+	return DataFilePath(SECRETS_FILE)
+}
+
+func (b *encryptedFileBackend) Get(email string) (string, bool, error) {
+	secrets, err := b.load()
+	if err != nil {
+		return "", false, err
+	}
+	password, exists := secrets[strings.ToLower(email)]
+	return password, exists, nil
+}
+
+func (b *encryptedFileBackend) Set(email, password string) error {
+	secrets, err := b.load()
+	if err != nil {
+		return err
+	}
+	secrets[strings.ToLower(email)] = password
+	return b.save(secrets)
+}
+
+func (b *encryptedFileBackend) Delete(email string) error {
+	secrets, err := b.load()
+	if err != nil {
+		return err
+	}
+	if _, exists := secrets[strings.ToLower(email)]; !exists {
+		return nil
+	}
+	delete(secrets, strings.ToLower(email))
+	return b.save(secrets)
+}
+
+// load decrypts all stored secrets. If there is no secrets file yet, there are no secrets.
+func (b *encryptedFileBackend) load() (map[string]string, error) {
+	path, err := secretsFilePath()
+	if err != nil {
+		return nil, err
+	}
+	fileBytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file secretsFile
+	if err := json.Unmarshal(fileBytes, &file); err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	salt, err := base64.StdEncoding.DecodeString(file.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("secrets file has an invalid salt: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(file.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("secrets file has an invalid nonce: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(file.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("secrets file has invalid ciphertext: %w", err)
+	}
+
+	if err := b.ensureKey(file.KDF, salt, false); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(b.key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		b.key = nil
+		return nil, fmt.Errorf("failed to decrypt secrets file; wrong passphrase or key?")
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to read decrypted secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+func (b *encryptedFileBackend) save(secrets map[string]string) error {
+	if b.key == nil {
+		// first time saving, so a new key is needed
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		if err := b.ensureKey("", salt, true); err != nil {
+			return err
+		}
+	}
+
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(b.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	file := secretsFile{
+		Version:    1,
+		KDF:        b.kdf,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+	}
+	if b.kdf == "scrypt" {
+		file.Salt = base64.StdEncoding.EncodeToString(b.salt)
+	}
+	fileBytes, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := EnsureConfigDir(); err != nil {
+		return err
+	}
+	path, err := secretsFilePath()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, fileBytes, 0600)
+}
+
+// ensureKey gets the encryption key, either from the env or by deriving it from a passphrase.
+// kdf is how the existing file was encrypted, and is empty for a new file.
+func (b *encryptedFileBackend) ensureKey(kdf string, salt []byte, isNew bool) error {
+	if b.key != nil {
+		return nil
+	}
+
+	if encodedKey := os.Getenv(SECRET_KEY_ENV); encodedKey != "" && kdf != "scrypt" {
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil || len(key) != secretKeyLen {
+			return fmt.Errorf("%s must be a base64 encoded %v byte key", SECRET_KEY_ENV, secretKeyLen)
+		}
+		b.key, b.kdf = key, "none"
+		return nil
+	}
+	if kdf == "none" {
+		return fmt.Errorf("secrets file was encrypted with a key; set %s to decrypt it", SECRET_KEY_ENV)
+	}
+
+	passphrase := os.Getenv(PASSPHRASE_ENV)
+	if passphrase == "" {
+		var err error
+		if isNew {
+			utils.HintTo(utils.Stderr(), "(passwords are stored encrypted. choose a passphrase to encrypt them with.)")
+		}
+		passphrase, err = utils.GetSecretInput("Secrets passphrase")
+		if err != nil {
+			return fmt.Errorf("%w; set %s or %s instead", err, PASSPHRASE_ENV, SECRET_KEY_ENV)
+		}
+		if isNew {
+			confirm, err := utils.GetSecretInput("Confirm passphrase")
+			if err != nil {
+				return err
+			}
+			if confirm != passphrase {
+				return errors.New("passphrases don't match")
+			}
+		}
+	}
+	if passphrase == "" {
+		return errors.New("passphrase can't be empty")
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, secretKeyLen)
+	if err != nil {
+		return err
+	}
+	b.key, b.salt, b.kdf = key, salt, "scrypt"
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
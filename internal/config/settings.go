@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// keys that can be used with GetValue and SetValue. user-defined variables are also available as "vars.<name>".
+var ConfigKeys = []string{"last_used_project", "last_login_user", "api_base_url", "console_base_url"}
+
+const varsKeyPrefix = "vars."
+
+// RemoveProject removes a registered project (by ID or display ID) from config.
+func (c *Config) RemoveProject(idOrDisplayID string) (*Project, error) {
+	for i, project := range c.Projects {
+		if project.P_ID == idOrDisplayID || project.DisplayID == idOrDisplayID {
+			c.Projects = append(c.Projects[:i], c.Projects[i+1:]...)
+			if c.LastUsedProject == project.P_ID {
+				c.LastUsedProject = ""
+			}
+			if err := c.Save(); err != nil {
+				return nil, err
+			}
+			return &project, nil
+		}
+	}
+	return nil, fmt.Errorf("project not registered: %s", idOrDisplayID)
+}
+
+// RemoveUser removes a registered user from config, along with their stored password and cached token for the selected environment.
+func (c *Config) RemoveUser(email string) (*User, error) {
+	for i, user := range c.Users {
+		if user.Email != email {
+			continue
+		}
+		if err := secretBackend.Delete(secretKey(c.envName, email)); err != nil {
+			return nil, fmt.Errorf("failed to remove stored password: %w", err)
+		}
+		// only the token for this environment goes; the user may still be registered in others
+		if err := (TokenCache{}).Delete(c.NewClient().BaseURL, email); err != nil {
+			return nil, fmt.Errorf("failed to clear cached token: %w", err)
+		}
+
+		c.Users = append(c.Users[:i], c.Users[i+1:]...)
+		if c.LastLoginUser == email {
+			c.LastLoginUser = ""
+		}
+		for j, project := range c.Projects {
+			if project.LastLoginUser == email {
+				c.Projects[j].LastLoginUser = ""
+			}
+		}
+		if err := c.Save(); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	return nil, fmt.Errorf("user not registered: %s", email)
+}
+
+// UseProject sets the project used by default, by ID or display ID. The project must be registered.
+func (c *Config) UseProject(idOrDisplayID string) (*Project, error) {
+	project := c.GetProject(idOrDisplayID)
+	if project == nil {
+		return nil, fmt.Errorf("project not registered: %s (registered: %s)", idOrDisplayID, c.registeredProjects())
+	}
+	c.LastUsedProject = project.P_ID
+	if err := c.Save(); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// GetValue gives the value of a config key (see ConfigKeys).
+func (c Config) GetValue(key string) (string, error) {
+	switch key {
+	case "last_used_project":
+		return c.LastUsedProject, nil
+	case "last_login_user":
+		return c.LastLoginUser, nil
+	case "api_base_url":
+		return c.ApiBaseURL, nil
+	case "console_base_url":
+		return c.ConsoleBaseURL, nil
+	}
+	if name, isVar := strings.CutPrefix(key, varsKeyPrefix); isVar && name != "" {
+		value, exists := c.Vars[name]
+		if !exists {
+			return "", fmt.Errorf("variable not set: %s", name)
+		}
+		return value, nil
+	}
+	return "", unknownKeyErr(key)
+}
+
+// SetValue changes the value of a config key (see ConfigKeys), and saves the config.
+// Projects and users must be registered to be set. An empty value unsets the key.
+func (c *Config) SetValue(key, value string) error {
+	switch key {
+	case "last_used_project":
+		if value != "" {
+			project := c.GetProject(value)
+			if project == nil {
+				return fmt.Errorf("project not registered: %s (registered: %s)", value, c.registeredProjects())
+			}
+			value = project.P_ID
+		}
+		c.LastUsedProject = value
+	case "last_login_user":
+		if value != "" && c.GetUser(value) == nil {
+			return fmt.Errorf("user not registered: %s", value)
+		}
+		c.LastLoginUser = value
+	case "api_base_url", "console_base_url":
+		if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+			return fmt.Errorf("%s must be an http or https URL", key)
+		}
+		if key == "api_base_url" {
+			c.ApiBaseURL = value
+		} else {
+			c.ConsoleBaseURL = value
+		}
+	default:
+		name, isVar := strings.CutPrefix(key, varsKeyPrefix)
+		if !isVar || name == "" {
+			return unknownKeyErr(key)
+		}
+		if value == "" {
+			delete(c.Vars, name)
+		} else {
+			if c.Vars == nil {
+				c.Vars = make(map[string]string)
+			}
+			c.Vars[name] = value
+		}
+	}
+	return c.Save()
+}
+
+// Values gives all config keys and their values, including user-defined variables.
+func (c Config) Values() map[string]string {
+	values := make(map[string]string)
+	for _, key := range ConfigKeys {
+		values[key], _ = c.GetValue(key)
+	}
+	for name, value := range c.Vars {
+		values[varsKeyPrefix+name] = value
+	}
+	return values
+}
+
+// registeredProjects lists the display IDs of all registered projects, for error messages
+func (c Config) registeredProjects() string {
+	if len(c.Projects) == 0 {
+		return "none"
+	}
+	displayIDs := make([]string, 0, len(c.Projects))
+	for _, project := range c.Projects {
+		displayIDs = append(displayIDs, project.DisplayID)
+	}
+	sort.Strings(displayIDs)
+	return strings.Join(displayIDs, ", ")
+}
+
+func unknownKeyErr(key string) error {
+	return fmt.Errorf("unknown config key: %s (available: %s, %s<name>)", key, strings.Join(ConfigKeys, ", "), varsKeyPrefix)
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+)
+
+// file (in the config directory) where login tokens are cached
+const TOKEN_CACHE_FILE = "tokens.json"
+
+// TokenCache stores login tokens in the config directory, by base URL and then by user email.
+// It implements hexaclient.TokenStore.
+type TokenCache struct{}
+
+type tokenCacheData map[string]map[string]string
+
+func tokenCachePath() (string, error) {
+	return DataFilePath(TOKEN_CACHE_FILE)
+}
+
+func loadTokenCache() (tokenCacheData, error) {
+	data := make(tokenCacheData)
+	path, err := tokenCachePath()
+	if err != nil {
+		return nil, err
+	}
+	cacheBytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(cacheBytes, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (data tokenCacheData) save() error {
+	if err := EnsureConfigDir(); err != nil {
+		return err
+	}
+	cacheBytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := tokenCachePath()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, cacheBytes, 0600)
+}
+
+func (TokenCache) Get(baseURL, email string) (string, bool) {
+	data, err := loadTokenCache()
+	if err != nil {
+		return "", false
+	}
+	token, exists := data[baseURL][strings.ToLower(email)]
+	return token, exists
+}
+
+func (TokenCache) Set(baseURL, email, token string) error {
+	data, err := loadTokenCache()
+	if err != nil {
+		// an unreadable cache is just replaced
+		data = make(tokenCacheData)
+	}
+	if data[baseURL] == nil {
+		data[baseURL] = make(map[string]string)
+	}
+	data[baseURL][strings.ToLower(email)] = token
+	return data.save()
+}
+
+func (TokenCache) Delete(baseURL, email string) error {
+	data, err := loadTokenCache()
+	if err != nil {
+		return err
+	}
+	if _, exists := data[baseURL][strings.ToLower(email)]; !exists {
+		return nil
+	}
+	delete(data[baseURL], strings.ToLower(email))
+	if len(data[baseURL]) == 0 {
+		delete(data, baseURL)
+	}
+	return data.save()
+}
+
+// ClearTokens removes cached tokens of the given user for all base URLs, or all cached tokens if email is empty.
+// The number of tokens removed is returned.
+func ClearTokens(email string) (int, error) {
+	data, err := loadTokenCache()
+	if err != nil {
+		// nothing worth keeping in an unreadable cache
+		path, pathErr := tokenCachePath()
+		if pathErr != nil {
+			return 0, pathErr
+		}
+		return 0, os.Remove(path)
+	}
+	removed := 0
+	for baseURL, tokens := range data {
+		for tokenEmail := range tokens {
+			if email == "" || tokenEmail == strings.ToLower(email) {
+				delete(tokens, tokenEmail)
+				removed++
+			}
+		}
+		if len(tokens) == 0 {
+			delete(data, baseURL)
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, data.save()
+}
@@ -0,0 +1,466 @@
+package console
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bwebb-hx/hxutil/internal/config"
+	"github.com/bwebb-hx/hxutil/internal/utils"
+	"golang.org/x/term"
+)
+
+// ANSI escape codes used to draw the console
+const (
+	altScreenOn  = "\x1b[?1049h"
+	altScreenOff = "\x1b[?1049l"
+	cursorHide   = "\x1b[?25l"
+	cursorShow   = "\x1b[?25h"
+	clearScreen  = "\x1b[H\x1b[2J"
+)
+
+type tab int
+
+const (
+	tabProjects tab = iota
+	tabUsers
+	tabEnvironments
+)
+
+var tabNames = []string{"Projects", "Users", "Environments"}
+
+// keys, as read from a terminal in raw mode
+const (
+	keyUp        = "\x1b[A"
+	keyDown      = "\x1b[B"
+	keyRight     = "\x1b[C"
+	keyLeft      = "\x1b[D"
+	keyShiftUp   = "\x1b[1;2A"
+	keyShiftDown = "\x1b[1;2B"
+	keyEnter     = "\r"
+	keyTab       = "\t"
+	keyEsc       = "\x1b"
+	keyCtrlC     = "\x03"
+	keyDelete    = "\x1b[3~"
+)
+
+// console is the state of the config console
+type console struct {
+	ctx    context.Context // parent of the contexts of suspended actions, such as logins
+	c      *config.Config
+	tab    tab
+	cursor [3]int
+	scroll [3]int
+	status string // message shown at the bottom, such as the result of the last action
+
+	// when set, the next key answers this yes/no question
+	confirm       string
+	confirmAction func() string
+
+	configPath string
+	err        error // set when the console can't carry on, such as when the terminal can't be switched back to raw mode
+
+	termState *term.State
+}
+
+// Run starts the config console, a keyboard driven UI for managing projects, users and environments.
+// It returns when the user quits. An error is returned if stdin or stdout is not a terminal.
+func Run(ctx context.Context) error {
+	if !IsTerminal() {
+		return errors.New("the config console requires a terminal")
+	}
+	c, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+	configPath, err := config.ConfigFilePath()
+	if err != nil {
+		return err
+	}
+
+	con := &console{ctx: ctx, c: c, configPath: configPath}
+	if err := con.enter(); err != nil {
+		return err
+	}
+	defer con.exit()
+
+	keys := bufio.NewReader(os.Stdin)
+	buf := make([]byte, 16)
+	for {
+		con.draw()
+		n, err := keys.Read(buf)
+		if err != nil {
+			return err
+		}
+		if quit := con.handleKey(string(buf[:n])); quit {
+			return nil
+		}
+		if con.err != nil {
+			return con.err
+		}
+	}
+}
+
+// IsTerminal is true if both stdin and stdout are terminals, so the console can be used.
+func IsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// enter switches the terminal to raw mode, on the alternate screen
+func (con *console) enter() error {
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	con.termState = state
+	fmt.Print(altScreenOn, cursorHide)
+	return nil
+}
+
+// exit restores the terminal to how it was before the console started
+func (con *console) exit() {
+	fmt.Print(cursorShow, altScreenOff)
+	if con.termState != nil {
+		term.Restore(int(os.Stdin.Fd()), con.termState)
+		con.termState = nil
+	}
+}
+
+// suspend leaves the console to run something that prints output or prompts for input (such as a login),
+// then returns to the console once the user presses Enter.
+// Each action has its own context, so Ctrl-C only cancels the action that is running, not the ones after it.
+func (con *console) suspend(title string, run func(ctx context.Context) error) {
+	con.exit()
+	fmt.Println(utils.ColorInfo.Sprint(title))
+	ctx, cancel := utils.InterruptContext(context.WithoutCancel(con.ctx))
+	err := run(ctx)
+	cancel()
+	if err != nil {
+		utils.Error(title+" failed", err.Error())
+		con.status = utils.ColorError.Sprint(title + " failed: " + err.Error())
+	} else {
+		utils.ColorSuccess.Println("Done!")
+		con.status = utils.ColorSuccess.Sprint(title + ": done")
+	}
+	utils.EnterToContinue()
+	if err := con.enter(); err != nil {
+		// can't get back into the console, so there's nothing else to do
+		con.err = fmt.Errorf("failed to return to the config console: %w", err)
+	}
+}
+
+// items gives the number of entries in the current tab
+func (con *console) items() int {
+	switch con.tab {
+	case tabProjects:
+		return len(con.c.Projects)
+	case tabUsers:
+		return len(con.c.Users)
+	default:
+		return len(con.c.EnvironmentNames())
+	}
+}
+
+// handleKey acts on a key press. true is returned when the console should close.
+func (con *console) handleKey(key string) bool {
+	if con.confirm != "" {
+		action := con.confirmAction
+		con.confirm, con.confirmAction = "", nil
+		if key == "y" || key == "Y" {
+			con.status = action()
+		} else {
+			con.status = "cancelled"
+		}
+		return false
+	}
+
+	con.status = ""
+	cursor := &con.cursor[con.tab]
+	switch key {
+	case "q", keyEsc, keyCtrlC:
+		return true
+	case keyTab, keyRight, "l":
+		con.tab = (con.tab + 1) % tab(len(tabNames))
+	case keyLeft, "h":
+		con.tab = (con.tab + tab(len(tabNames)) - 1) % tab(len(tabNames))
+	case "1", "2", "3":
+		con.tab = tab(key[0] - '1')
+	case keyUp, "k":
+		*cursor = max(0, *cursor-1)
+	case keyDown, "j":
+		*cursor = min(con.items()-1, *cursor+1)
+	case keyShiftUp, "K":
+		con.move(-1)
+	case keyShiftDown, "J":
+		con.move(1)
+	case keyEnter, " ":
+		con.setDefault()
+	case "d", "x", keyDelete:
+		con.remove()
+	case "t":
+		con.testLogin()
+	case "r":
+		con.refresh()
+	}
+	// keep the cursor in range, since entries may have been removed
+	*cursor = max(0, min(*cursor, con.items()-1))
+	return false
+}
+
+// move reorders the selected entry. environments are always listed by name, so they can't be reordered.
+func (con *console) move(delta int) {
+	cursor := &con.cursor[con.tab]
+	to := *cursor + delta
+	if to < 0 || to >= con.items() {
+		return
+	}
+	swap := func() {
+		switch con.tab {
+		case tabProjects:
+			con.c.Projects[*cursor], con.c.Projects[to] = con.c.Projects[to], con.c.Projects[*cursor]
+		case tabUsers:
+			con.c.Users[*cursor], con.c.Users[to] = con.c.Users[to], con.c.Users[*cursor]
+		}
+	}
+	if con.tab != tabProjects && con.tab != tabUsers {
+		con.status = "environments are listed by name, and can't be reordered"
+		return
+	}
+	swap()
+	if err := con.c.Save(); err != nil {
+		// put it back, so what's shown matches the config file
+		swap()
+		con.status = utils.ColorError.Sprint(err.Error())
+		return
+	}
+	*cursor = to
+}
+
+// setDefault makes the selected entry the last used one, or switches to the selected environment
+func (con *console) setDefault() {
+	if con.items() == 0 {
+		return
+	}
+	cursor := con.cursor[con.tab]
+	switch con.tab {
+	case tabProjects:
+		project := con.c.Projects[cursor]
+		if _, err := con.c.UseProject(project.P_ID); err != nil {
+			con.status = utils.ColorError.Sprint(err.Error())
+			return
+		}
+		con.status = "using project " + project.DisplayID
+	case tabUsers:
+		user := con.c.Users[cursor]
+		if err := con.c.SetValue("last_login_user", user.Email); err != nil {
+			con.status = utils.ColorError.Sprint(err.Error())
+			return
+		}
+		con.status = "default user set to " + user.Email
+	case tabEnvironments:
+		name := con.c.EnvironmentNames()[cursor]
+		if err := config.UseEnvironment(name); err != nil {
+			con.status = utils.ColorError.Sprint(err.Error())
+			return
+		}
+		c, err := config.GetConfig()
+		if err != nil {
+			con.status = utils.ColorError.Sprint(err.Error())
+			return
+		}
+		con.c = c
+		con.cursor[tabProjects], con.cursor[tabUsers] = 0, 0
+		con.status = "switched to environment " + name + " (for this session only; use --env or $" + config.ENV_VAR + " to select it for commands)"
+	}
+}
+
+// remove asks to delete the selected entry
+func (con *console) remove() {
+	if con.items() == 0 {
+		return
+	}
+	cursor := con.cursor[con.tab]
+	switch con.tab {
+	case tabProjects:
+		project := con.c.Projects[cursor]
+		con.confirm = fmt.Sprintf("Remove project %s?", project.DisplayID)
+		con.confirmAction = func() string {
+			if _, err := con.c.RemoveProject(project.P_ID); err != nil {
+				return utils.ColorError.Sprint(err.Error())
+			}
+			return "removed project " + project.DisplayID
+		}
+	case tabUsers:
+		user := con.c.Users[cursor]
+		con.confirm = fmt.Sprintf("Remove user %s (and their stored password)?", user.Email)
+		con.confirmAction = func() string {
+			// the secrets file may need a passphrase to be entered
+			con.suspend("Remove user "+user.Email, func(ctx context.Context) error {
+				_, err := con.c.RemoveUser(user.Email)
+				return err
+			})
+			return con.status
+		}
+	case tabEnvironments:
+		name := con.c.EnvironmentNames()[cursor]
+		con.confirm = fmt.Sprintf("Remove environment %s, with all of its users and projects?", name)
+		con.confirmAction = func() string {
+			if err := con.c.RemoveEnvironment(name); err != nil {
+				return utils.ColorError.Sprint(err.Error())
+			}
+			return "removed environment " + name
+		}
+	}
+}
+
+// testLogin logs in as the selected user, ignoring any cached token
+func (con *console) testLogin() {
+	if con.tab != tabUsers || con.items() == 0 {
+		con.status = "select a user to test login"
+		return
+	}
+	user := con.c.Users[con.cursor[tabUsers]]
+	con.suspend("Test login as "+user.Email, func(ctx context.Context) error {
+		return user.TestLogin(ctx, con.c.NewClient())
+	})
+}
+
+// refresh updates the details of the selected project from hexabase
+func (con *console) refresh() {
+	if con.tab != tabProjects || con.items() == 0 {
+		con.status = "select a project to refresh"
+		return
+	}
+	project := con.c.Projects[con.cursor[tabProjects]]
+	con.suspend("Refresh project "+project.DisplayID, func(ctx context.Context) error {
+		email := project.LastLoginUser
+		if email == "" {
+			email = con.c.LastLoginUser
+		}
+		user := con.c.GetUser(email)
+		if user == nil {
+			return errors.New("no user to login with; set a default user first")
+		}
+		client := con.c.NewClient()
+		if err := user.Login(ctx, client); err != nil {
+			return err
+		}
+		refreshed, err := con.c.RefreshProject(ctx, client, project.P_ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Refreshed: %s %s  %s\n", refreshed.DisplayID, refreshed.P_ID, refreshed.WorkspaceName)
+		return nil
+	})
+}
+
+func (con *console) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width == 0 || height == 0 {
+		width, height = 80, 24
+	}
+
+	lines := make([]string, 0, height)
+	lines = append(lines, utils.ColorInfo.Sprint("HXUTIL Config Console")+utils.ColorHint.Sprintf("  env: %s  config: %s", config.SelectedEnvironment(), con.configPath))
+
+	tabs := make([]string, len(tabNames))
+	for i, name := range tabNames {
+		label := fmt.Sprintf(" %v %s ", i+1, name)
+		if tab(i) == con.tab {
+			label = utils.ColorSuccess.Sprint("[" + label + "]")
+		} else {
+			label = " " + label + " "
+		}
+		tabs[i] = label
+	}
+	lines = append(lines, strings.Join(tabs, ""), "")
+
+	entries := con.entries()
+	// the header, footer and status take up the rest of the screen
+	listHeight := max(1, height-len(lines)-4)
+	cursor, scroll := con.cursor[con.tab], &con.scroll[con.tab]
+	if cursor < *scroll {
+		*scroll = cursor
+	} else if cursor >= *scroll+listHeight {
+		*scroll = cursor - listHeight + 1
+	}
+	if len(entries) == 0 {
+		lines = append(lines, utils.ColorHint.Sprint("  (none)"))
+	}
+	for i := *scroll; i < len(entries) && i < *scroll+listHeight; i++ {
+		entry := truncate(entries[i], width-3)
+		if i == cursor {
+			lines = append(lines, utils.ColorSuccess.Sprint("> ")+entry)
+		} else {
+			lines = append(lines, "  "+entry)
+		}
+	}
+	for len(lines) < height-3 {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, "")
+	if con.confirm != "" {
+		lines = append(lines, utils.ColorWarn.Sprint(con.confirm+" [y/N]"))
+	} else {
+		lines = append(lines, con.status)
+	}
+	lines = append(lines, utils.ColorHint.Sprint(truncate(con.help(), width)))
+
+	fmt.Print(clearScreen, strings.Join(lines, "\r\n"))
+}
+
+// entries gives the lines listed in the current tab. "*" marks the last used (or selected) entry.
+func (con *console) entries() []string {
+	entries := make([]string, 0)
+	switch con.tab {
+	case tabProjects:
+		for _, project := range con.c.Projects {
+			entries = append(entries, fmt.Sprintf("%s %-24s %s  %s  %s", marker(project.P_ID == con.c.LastUsedProject),
+				project.DisplayID, project.P_ID, project.WorkspaceName, project.LastLoginUser))
+		}
+	case tabUsers:
+		for _, user := range con.c.Users {
+			entries = append(entries, fmt.Sprintf("%s %s", marker(user.Email == con.c.LastLoginUser), user.Email))
+		}
+	case tabEnvironments:
+		for _, name := range con.c.EnvironmentNames() {
+			entries = append(entries, fmt.Sprintf("%s %s", marker(name == config.SelectedEnvironment()), name))
+		}
+	}
+	return entries
+}
+
+func (con *console) help() string {
+	common := "tab/←→ switch  ↑↓/jk move  q quit"
+	switch con.tab {
+	case tabProjects:
+		return "enter use project  r refresh  K/J reorder  d delete  " + common
+	case tabUsers:
+		return "enter set default  t test login  K/J reorder  d delete  " + common
+	default:
+		return "enter switch  d delete  " + common
+	}
+}
+
+func marker(selected bool) string {
+	if selected {
+		return "*"
+	}
+	return " "
+}
+
+// truncate shortens s to fit in width columns. colored strings are not truncated, since escape codes take up no space.
+func truncate(s string, width int) string {
+	if width <= 0 || strings.Contains(s, "\x1b") {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:max(0, width-1)]) + "…"
+}
@@ -10,6 +10,22 @@ type ApiEndpoint struct {
 	RequirePayload bool
 }
 
+// ApiEndpoints are all the registered APIs, by name. This is how API test suites refer to endpoints.
+var ApiEndpoints = map[string]*ApiEndpoint{
+	"Login":                         &LoginAPI,
+	"ForgotPassword":                &ForgotPasswordAPI,
+	"GetUserInfo":                   &GetUserInfoAPI,
+	"GetWorkspaces":                 &GetWorkspacesAPI,
+	"GetActions":                    &GetActionsAPI,
+	"DownloadActionScript":          &DownloadActionScriptAPI,
+	"UploadActionScript":            &UploadActionScriptAPI,
+	"GetApplicationScriptVariable":  &GetApplicationScriptVariableAPI,
+	"GetDatastores":                 &GetDatastoresAPI,
+	"UN_GetFunctionActionScript":    &UN_GetFunctionActionScriptAPI,
+	"UN_UpdateFunctionActionScript": &UN_UpdateFunctionActionScriptAPI,
+	"UN_GetProjectSettings":         &UN_GetProjectSettingsAPI,
+}
+
 type LoginPayload struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -96,6 +112,20 @@ var DownloadActionScriptAPI = ApiEndpoint{
 	RequirePayload: false,
 }
 
+// Based on: https://github.com/hexabase/hexabase-cli/blob/master/src/commands/actions/scripts/upload.ts
+//
+// Form Fields (multipart):
+//
+// - filename: the actionscript file
+// - script_type: "pre" or "post"
+var UploadActionScriptAPI = ApiEndpoint{
+	URI:            "/api/v0/actions/%s/actionscripts/upload",
+	DisplayURI:     "/api/v0/actions/:action_id/actionscripts/upload",
+	Method:         POST,
+	RequireToken:   true,
+	RequirePayload: true,
+}
+
 var GetApplicationScriptVariableAPI = ApiEndpoint{
 	URI:            "/api/v0/applications/%s/script/%s",
 	DisplayURI:     "/api/v0/applications/:app-id/script/:var-name",
@@ -112,7 +142,9 @@ var GetDatastoresAPI = ApiEndpoint{
 	RequirePayload: false,
 }
 
-type GetDatastoresResponse []struct {
+type GetDatastoresResponse []Datastore
+
+type Datastore struct {
 	DatastoreID string `json:"datastore_id"`
 	Name        string `json:"name"`
 	DisplayID   string `json:"display_id"`
@@ -124,6 +156,7 @@ type GetDatastoresResponse []struct {
 // APP.HEXABASE.COM APIS
 // The following are not officially published APIs, but ones that I've found while investigating the
 // hexabase management console site using the network inspector
+// They are served by the management console, so their URIs are resolved against the console base URL (see CONSOLE_PREFIX).
 // I will prefix all of these APIs with "UN" ("unofficial") until they are replaced with officially documented APIs.
 
 // (UNOFFICIAL)
@@ -132,7 +165,7 @@ type GetDatastoresResponse []struct {
 //
 // - p_id: application (project) ID where the function is defined
 var UN_GetFunctionActionScriptAPI = ApiEndpoint{
-	URI:            "https://app.hexabase.com/v1/api/get_action_scripts",
+	URI:            CONSOLE_PREFIX + "/v1/api/get_action_scripts",
 	DisplayURI:     "(UN) /v1/api/get_action_scripts",
 	Method:         GET,
 	RequireToken:   true,
@@ -155,13 +188,34 @@ type UN_GetFunctionActionScriptResponse []struct {
 	WaitResponse bool   `json:"wait_response"`
 }
 
+// (UNOFFICIAL)
+//
+// Saves the script of a function. This is the request the management console sends when saving a function in the script editor.
+var UN_UpdateFunctionActionScriptAPI = ApiEndpoint{
+	URI:            CONSOLE_PREFIX + "/v1/api/update_action_script",
+	DisplayURI:     "(UN) /v1/api/update_action_script",
+	Method:         POST,
+	RequireToken:   true,
+	RequirePayload: true,
+}
+
+type UN_UpdateFunctionActionScriptPayload struct {
+	ID         string `json:"_id"`
+	FunctionID string `json:"fn_id"`
+	PID        string `json:"p_id"`
+	Pre        struct {
+		Script     string `json:"script"`
+		TimeoutSec int    `json:"timeout_sec"`
+	} `json:"pre"`
+}
+
 // (UNOFFICIAL)
 //
 // Query Params:
 //
 // - p_id: application (project) ID
 var UN_GetProjectSettingsAPI = ApiEndpoint{
-	URI:            "https://app.hexabase.com/v1/api/get_project_settings",
+	URI:            CONSOLE_PREFIX + "/v1/api/get_project_settings",
 	DisplayURI:     "(UN) /v1/api/get_project_settings",
 	Method:         GET,
 	RequireToken:   true,
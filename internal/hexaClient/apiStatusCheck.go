@@ -1,11 +1,11 @@
 package hexaclient
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
@@ -25,13 +25,11 @@ var (
 
 const (
 	// Dear hackers: these credentials are only for testing, so they don't protect anything important.
-	TestAccUser   = "b.webb+test@hexabase.com"
-	TestAccPass   = "test123"
-	TestP_ID      = "674716ff253630d46156a153"
-	TestD_ID      = "674724ac4ba983711e015530"
-	testAction_ID = "674724acb7eeb7dd909dd15b"
-	testVarName   = "ENV_VARIABLE_1"
-	testFuncName  = "function1"
+	// (the test data used by the status check lives in defaultSuite.yaml)
+	TestAccUser = "b.webb+test@hexabase.com"
+	TestAccPass = "test123"
+	TestP_ID    = "674716ff253630d46156a153"
+	TestD_ID    = "674724ac4ba983711e015530"
 )
 
 const (
@@ -39,272 +37,232 @@ const (
 	EXISTS_CHECK = "<<EXISTS>>"
 
 	// methods
-	GET  = "GET"
-	POST = "POST"
+	GET    = "GET"
+	POST   = "POST"
+	PUT    = "PUT"
+	PATCH  = "PATCH"
+	DELETE = "DELETE"
 )
 
-func payloadToJson(data interface{}) []byte {
+// HTTP methods that can be used to call APIs
+var SupportedMethods = []string{GET, POST, PUT, PATCH, DELETE}
+
+// IsSupportedMethod is true if method (in upper case) is one of SupportedMethods
+func IsSupportedMethod(method string) bool {
+	for _, supported := range SupportedMethods {
+		if method == supported {
+			return true
+		}
+	}
+	return false
+}
+
+func payloadToJson(data interface{}) ([]byte, error) {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		log.Fatal("error converting payload to json:", err)
+		return nil, fmt.Errorf("error converting payload to json: %w", err)
 	}
-	return jsonData
+	return jsonData, nil
 }
 
-// testApi tests the given API with the given payload.
-func testApi(apiDef ApiEndpoint, formatURI []any, queryParams map[string]string, payload interface{}, evalFunc func(data []byte) error, n int, wg *sync.WaitGroup) {
-	defer func() {
-		if wg != nil {
-			wg.Done()
-		}
-	}()
+// ApiTestResult is the outcome of running an API test case
+type ApiTestResult struct {
+	Name    string
+	ApiDef  ApiEndpoint
+	Pass    int
+	Fail    int
+	Retries int // total retries across all calls
+	Stats   LatencyStats
+}
 
-	if formatURI != nil {
-		apiDef.URI = fmt.Sprintf(apiDef.URI, formatURI...)
-	}
+// Endpoint gives the method and general URI of the tested API, such as "GET /api/v0/workspaces"
+func (r ApiTestResult) Endpoint() string {
+	return r.ApiDef.Method + " " + r.ApiDef.DisplayURI
+}
 
-	var totalTime time.Duration
-	pass, fail := 0, 0
+// testApi runs an API test case n times, and prints the results.
+// Every call is timed, including ones that fail.
+func testApi(ctx context.Context, client *Client, apiDef ApiEndpoint, test ApiTest) ApiTestResult {
+	n := test.Repeat
+	result := ApiTestResult{Name: test.Name, ApiDef: apiDef}
 
 	if n == 0 {
 		fmt.Println(apiDef.URI, "(n = 0; abort)")
-		return
+		return result
+	}
+	if !IsSupportedMethod(apiDef.Method) {
+		log.Println("Error: unknown HTTP method", apiDef.Method)
+		return result
+	}
+
+	req, err := test.request(apiDef)
+	if err != nil {
+		log.Println("Error:", err)
+		return result
 	}
 
+	latencies := make([]time.Duration, 0, n)
+	ttfbs := make([]time.Duration, 0, n)
 	for i := 0; i < n; i++ {
-		start := time.Now()
-		var resp []byte
-		var err error
-		if apiDef.Method == GET {
-			resp, err = GetApi(apiDef.URI, queryParams)
-		} else if apiDef.Method == POST {
-			resp, err = PostApi(apiDef.URI, payloadToJson(payload))
-		} else {
-			log.Println("Error: unknown HTTP method", apiDef.Method)
-			return
+		resp, err := client.Do(ctx, req)
+		if ctx.Err() != nil {
+			// interrupted, so the call didn't really fail
+			break
 		}
+		latencies = append(latencies, resp.Duration)
+		result.Retries += resp.Retries
 		if err != nil {
 			log.Println("failed to call API:", err)
-			return
+			result.Fail++
+			continue
 		}
-		totalTime += time.Since(start)
+		ttfbs = append(ttfbs, resp.TTFB)
 
-		err = evalFunc(resp)
+		err = test.Assert.Eval(resp.StatusCode, resp.Body, resp.Duration)
 
 		if err != nil {
 			log.Println(apiDef.URI, err)
-			fail++
+			result.Fail++
 		} else {
-			pass++
+			result.Pass++
 		}
 	}
+	result.Stats = computeLatencyStats(latencies, ttfbs, result.Fail)
 
-	averageExecTime := totalTime / time.Duration(n)
-	status := fmt.Sprintf("%v/%v", pass, n)
-	if fail > 0 {
+	status := fmt.Sprintf("%v/%v", result.Pass, n)
+	if result.Fail > 0 {
 		status += " (FAIL)"
 	} else {
 		status += " (Pass!)"
 	}
-	method := apiDef.Method
-	if method == "GET" {
-		method += " "
-	}
+	method := fmt.Sprintf("%-4s", apiDef.Method)
 
 	c := failColor
-	if fail == 0 {
+	if result.Fail == 0 {
 		c = passColor
 	}
-	c.Printf("%s %s %s %s ms\n", method, apiDef.DisplayURI, status, speedometer(averageExecTime.Milliseconds()))
+	stats := result.Stats
+	c.Printf("%s %s %s %s ms\n", method, apiDef.DisplayURI, status, speedometer(stats.Mean.Milliseconds()))
+	fmt.Printf("    min %s  p50 %s  p90 %s  p99 %s  max %s  σ %v  ttfb %s  err %.0f%%  retries %v\n",
+		speedometer(stats.Min.Milliseconds()),
+		speedometer(stats.P50.Milliseconds()),
+		speedometer(stats.P90.Milliseconds()),
+		speedometer(stats.P99.Milliseconds()),
+		speedometer(stats.Max.Milliseconds()),
+		stats.StdDev.Milliseconds(),
+		speedometer(stats.MeanTTFB.Milliseconds()),
+		stats.ErrorRate*100,
+		result.Retries,
+	)
+	return result
 }
 
 func speedometer(ms int64) string {
+	return speedometerPad(ms, 0)
+}
+
+// speedometerPad colors ms by how fast it is, padding it to the given width so it lines up in tables.
+func speedometerPad(ms int64, width int) string {
+	s := fmt.Sprintf("%*d", width, ms)
 	if ms <= 100 {
-		return speedFastColor.Sprint(ms)
+		return speedFastColor.Sprint(s)
 	}
 	if ms <= 300 {
-		return speedOkayColor.Sprint(ms)
+		return speedOkayColor.Sprint(s)
 	}
 	if ms <= 1000 {
-		return speedPoorColor.Sprint(ms)
+		return speedPoorColor.Sprint(s)
 	}
 	if ms <= 5000 {
-		return speedSlowColor.Sprint(ms)
+		return speedSlowColor.Sprint(s)
 	}
-	return speedDeadColor.Sprint(ms)
+	return speedDeadColor.Sprint(s)
 }
 
-func login() string {
-	return Login(TestAccUser, TestAccPass)
-}
+// printSummaryTable shows the latency stats of all tests, slowest first.
+func printSummaryTable(results []ApiTestResult) {
+	sorted := make([]ApiTestResult, len(results))
+	copy(sorted, results)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Stats.Mean > sorted[j].Stats.Mean })
 
-// RunStatusCheck tests the connectivity, response time, etc of all APIs (well, those that are registered here so far).
-func RunStatusCheck() {
-	var wg sync.WaitGroup
-	n := 3
-
-	// functions that represent an API test case.
-	apis := []func(){
-		func() {
-			// Login
-			testApi(LoginAPI, nil, nil, LoginPayload{
-				Email:    TestAccUser,
-				Password: TestAccPass,
-			}, func(data []byte) error {
-				var respJson map[string]interface{}
-				if err := json.Unmarshal(data, &respJson); err != nil {
-					return err
-				}
-				if _, exists := respJson["token"]; !exists {
-					return errors.New("missing token in response")
-				}
-				return nil
-			}, n, &wg)
-		},
-		func() {
-			// GetWorkspaces
-			testApi(GetWorkspacesAPI, nil, nil, nil, func(data []byte) error {
-				var respJson map[string]interface{}
-				if err := json.Unmarshal(data, &respJson); err != nil {
-					return err
-				}
-				if _, exists := respJson["workspaces"]; !exists {
-					return errors.New("missing workspaces in response")
-				}
-				return nil
-			}, n, &wg)
-		},
-		func() {
-			// GetActions
-			testApi(GetActionsAPI, []any{TestD_ID}, nil, nil, func(data []byte) error {
-				var respJson []map[string]interface{}
-				if err := json.Unmarshal(data, &respJson); err != nil {
-					return err
-				}
-				if len(respJson) == 0 {
-					return errors.New("no action details found in response")
-				}
-				return nil
-			}, n, &wg)
-		},
-		func() {
-			// DownloadActionScript
-			testApi(DownloadActionScriptAPI, []any{testAction_ID}, map[string]string{"script_type": "post"}, nil, func(data []byte) error {
-				actionScript := string(data)
-				if !strings.Contains(actionScript, "main(data)") {
-					return errors.New("failed to load actionscript")
-				}
-				return nil
-			}, n, &wg)
-		},
-		func() {
-			// get env variables
-			testApi(GetApplicationScriptVariableAPI, []any{TestP_ID, testVarName}, nil, nil, func(data []byte) error {
-				var jsonData map[string]interface{}
-				if err := json.Unmarshal(data, &jsonData); err != nil {
-					return err
-				}
-				if val, exists := jsonData["var_name"]; !exists || val != testVarName {
-					return errors.New("environment variable name incorrect or missing")
-				}
-				if val, exists := jsonData["value"]; !exists || val != "secret value" {
-					return errors.New("environment variable value incorrect or missing")
-				}
-				return nil
-			}, n, &wg)
-		},
-		func() {
-			// GetDatastores
-			testApi(GetDatastoresAPI, []any{TestP_ID}, nil, nil, func(data []byte) error {
-				var jsonData []map[string]interface{}
-				if err := json.Unmarshal(data, &jsonData); err != nil {
-					return err
-				}
-				if len(jsonData) == 0 {
-					return errors.New("no datastores found")
-				}
-				if _, exists := jsonData[0]["datastore_id"]; !exists {
-					return errors.New("datastore_id not found in response")
-				}
-				return nil
-			}, n, &wg)
-		},
-		func() {
-			// (UN) GetFunctionActionScript
-			testApi(UN_GetFunctionActionScriptAPI, nil, map[string]string{"p_id": TestP_ID}, nil, func(data []byte) error {
-				var jsonData UN_GetFunctionActionScriptResponse
-				if err := json.Unmarshal(data, &jsonData); err != nil {
-					return err
-				}
-				if len(jsonData) == 0 {
-					return errors.New("response contains no functions")
-				}
-				function := jsonData[0]
-				if function.DisplayID != testFuncName {
-					return errors.New("incorrect function name")
-				}
-				if !strings.Contains(function.Pre.Script, "async function main(data)") {
-					return errors.New("function actionscript contents are missing")
-				}
-				return nil
-			}, n, &wg)
-		},
-		func() {
-			// (UN) GetProjectSettings
-			testApi(UN_GetProjectSettingsAPI, nil, map[string]string{"p_id": TestP_ID}, nil, func(data []byte) error {
-				var jsonData UN_GetProjectSettingsResponse
-				if err := json.Unmarshal(data, &jsonData); err != nil {
-					return err
-				}
-				if jsonData.PID != TestP_ID {
-					return errors.New("pid is incorrect")
-				}
-				varFound := false
-				for _, scriptVar := range jsonData.ScriptVars {
-					if scriptVar.VarName == testVarName {
-						varFound = true
-						break
-					}
-				}
-				if !varFound {
-					return errors.New("env var not found")
-				}
-				return nil
-			}, n, &wg)
-		},
+	uriWidth := len("ENDPOINT")
+	for _, result := range sorted {
+		uriWidth = max(uriWidth, len(result.ApiDef.DisplayURI))
 	}
 
-	// NoAuth APIs
-	testApi(ForgotPasswordAPI, nil, nil, ForgotPasswordPayload{Email: TestAccUser, Host: baseURL}, func(data []byte) error {
-		var resp map[string]interface{}
-		err := json.Unmarshal(data, &resp)
-		if err != nil {
-			return err
+	fmt.Println("\n== SUMMARY (ms, slowest first) ==")
+	fmt.Printf("%-4s %-*s %6s %6s %6s %6s %6s %6s %6s %6s %5s %5s\n", "", uriWidth, "ENDPOINT", "MEAN", "MIN", "P50", "P90", "P99", "MAX", "STDDEV", "TTFB", "ERR%", "RETRY")
+	for _, result := range sorted {
+		stats := result.Stats
+		fmt.Printf("%-4s %-*s %s %s %s %s %s %s %6d %s %5.0f %5d\n",
+			result.ApiDef.Method,
+			uriWidth, result.ApiDef.DisplayURI,
+			speedometerPad(stats.Mean.Milliseconds(), 6),
+			speedometerPad(stats.Min.Milliseconds(), 6),
+			speedometerPad(stats.P50.Milliseconds(), 6),
+			speedometerPad(stats.P90.Milliseconds(), 6),
+			speedometerPad(stats.P99.Milliseconds(), 6),
+			speedometerPad(stats.Max.Milliseconds(), 6),
+			stats.StdDev.Milliseconds(),
+			speedometerPad(stats.MeanTTFB.Milliseconds(), 6),
+			stats.ErrorRate*100,
+			result.Retries,
+		)
+	}
+}
+
+// RunStatusCheck tests the connectivity, response time, etc of the APIs in the given suite.
+// Tests that don't require auth are run first, and then the rest are run after logging in the client with the suite's credentials.
+// If login fails or ctx is cancelled, the results of the tests that were run are still given, along with the error.
+func RunStatusCheck(ctx context.Context, client *Client, suite *ApiTestSuite) ([]ApiTestResult, error) {
+	fmt.Printf("running test suite: %s (%v tests)\n", suite.Name, len(suite.Tests))
+
+	noAuthTests := make([]ApiTest, 0)
+	authTests := make([]ApiTest, 0)
+	for _, test := range suite.Tests {
+		apiDef, _ := suite.resolve(client, test)
+		if apiDef.RequireToken {
+			authTests = append(authTests, test)
+		} else {
+			noAuthTests = append(noAuthTests, test)
 		}
-		val, exists := resp["valid_email"]
-		if !exists {
-			fmt.Println(resp)
-			return errors.New("valid_email key is missing")
+	}
+
+	results := make([]ApiTestResult, 0, len(suite.Tests))
+	runTests := func(tests []ApiTest) {
+		var wg sync.WaitGroup
+		testResults := make([]ApiTestResult, len(tests))
+		wg.Add(len(tests))
+		// run each api test concurrently
+		for i, test := range tests {
+			apiDef, resolvedTest := suite.resolve(client, test)
+			go func() {
+				defer wg.Done()
+				testResults[i] = testApi(ctx, client, apiDef, resolvedTest)
+			}()
 		}
-		if val != true {
-			return errors.New("email is expected to be valid")
+		wg.Wait()
+		results = append(results, testResults...)
+	}
+
+	// NoAuth APIs
+	runTests(noAuthTests)
+
+	if len(authTests) > 0 && ctx.Err() == nil {
+		// Login to set the auth token for auth APIs
+		if _, err := client.LoginFresh(ctx, suite.expand(client, suite.Login.Email), suite.expand(client, suite.Login.Password)); err != nil {
+			printSummaryTable(results)
+			return results, fmt.Errorf("failed to login for tests that require auth: %w", err)
 		}
-		return nil
-	}, n, nil)
+		fmt.Println("(login succeeded)")
 
-	// Login to set the auth token for auth APIs
-	token := login()
-	if token == "" {
-		log.Fatal("failed to get token")
+		runTests(authTests)
 	}
-	fmt.Println("(login succeeded)")
 
-	// run each api test concurrently
-	wg.Add(len(apis))
-	for _, fn := range apis {
-		go fn()
+	printSummaryTable(results)
+	if ctx.Err() != nil {
+		return results, fmt.Errorf("interrupted: %w", ctx.Err())
 	}
-
-	wg.Wait()
 	fmt.Println("done!")
+	return results, nil
 }
@@ -0,0 +1,350 @@
+package hexaclient
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed defaultSuite.yaml
+var defaultSuiteYaml []byte
+
+// ApiTestSuite is a set of API test cases, usually loaded from a YAML or JSON file.
+type ApiTestSuite struct {
+	Name   string            `yaml:"name"`
+	Repeat int               `yaml:"repeat"` // default number of times to run each test
+	Vars   map[string]string `yaml:"vars"`   // variables that can be used as {{name}} in test cases
+	Login  struct {
+		Email    string `yaml:"email"`
+		Password string `yaml:"password"`
+	} `yaml:"login"` // credentials used for tests that require auth
+	Tests []ApiTest `yaml:"tests"`
+}
+
+// ApiTest is a single API test case
+type ApiTest struct {
+	Name string `yaml:"name"`
+
+	// name of a registered API (see ApiEndpoints). alternatively, set URI and Method to test an unregistered API.
+	Endpoint string `yaml:"endpoint"`
+	URI      string `yaml:"uri"`
+	Method   string `yaml:"method"` // GET, POST, PUT, PATCH or DELETE
+
+	PathParams []string          `yaml:"path_params"` // values to format into the URI
+	Query      map[string]string `yaml:"query"`
+	Headers    map[string]string `yaml:"headers"`
+	Payload    interface{}       `yaml:"payload"`
+	Auth       *bool             `yaml:"auth"`   // defaults to whether the endpoint requires a token
+	Repeat     int               `yaml:"repeat"` // defaults to the suite's repeat count
+
+	Assert ApiAssertions `yaml:"assert"`
+}
+
+// ApiAssertions are the checks done against each response of an API test.
+// JSON paths are dot separated keys, such as "workspaces.0.workspace_id". "*" matches any element of an array.
+type ApiAssertions struct {
+	Status       int                    `yaml:"status"`
+	JsonExists   []string               `yaml:"json_exists"`
+	JsonEquals   map[string]interface{} `yaml:"json_equals"`
+	BodyContains []string               `yaml:"body_contains"`
+	MaxLatencyMs int64                  `yaml:"max_latency_ms"`
+}
+
+// DefaultApiTestSuite gives the suite that ships with hxutil.
+func DefaultApiTestSuite() (*ApiTestSuite, error) {
+	return parseApiTestSuite(defaultSuiteYaml)
+}
+
+// LoadApiTestSuite loads a suite from a YAML or JSON file.
+// A suite without a name is named after the file, so its runs aren't mixed up with other suites in history.
+func LoadApiTestSuite(path string) (*ApiTestSuite, error) {
+	suiteBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	suite, err := parseApiTestSuite(suiteBytes)
+	if err != nil {
+		return nil, err
+	}
+	if suite.Name == "" {
+		base := filepath.Base(path)
+		suite.Name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return suite, nil
+}
+
+func parseApiTestSuite(suiteBytes []byte) (*ApiTestSuite, error) {
+	// JSON is (for our purposes) a subset of YAML, so this handles both
+	var suite ApiTestSuite
+	if err := yaml.Unmarshal(suiteBytes, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse test suite: %w", err)
+	}
+	if suite.Repeat == 0 {
+		suite.Repeat = 1
+	}
+	for i, test := range suite.Tests {
+		if test.Endpoint != "" {
+			if _, exists := ApiEndpoints[test.Endpoint]; !exists {
+				return nil, fmt.Errorf("test %q: unknown endpoint %q", test.Name, test.Endpoint)
+			}
+		} else if test.URI == "" || test.Method == "" {
+			return nil, fmt.Errorf("test %q: either endpoint, or uri and method, must be set", test.Name)
+		} else if !IsSupportedMethod(strings.ToUpper(test.Method)) {
+			return nil, fmt.Errorf("test %q: unsupported method %q", test.Name, test.Method)
+		}
+		if test.Repeat == 0 {
+			suite.Tests[i].Repeat = suite.Repeat
+		}
+	}
+	return &suite, nil
+}
+
+// Filter keeps only the tests with the given names. An error is returned if a name doesn't match any test.
+func (suite *ApiTestSuite) Filter(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	tests := make([]ApiTest, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, test := range suite.Tests {
+			if test.Name == name {
+				tests = append(tests, test)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("test not found in suite %s: %s", suite.Name, name)
+		}
+	}
+	suite.Tests = tests
+	return nil
+}
+
+// endpoints that deal with credentials. calling them repeatedly can lock accounts or send emails to real users.
+var authEndpoints = []*ApiEndpoint{&LoginAPI, &ForgotPasswordAPI}
+
+// LoadSafe is true if a test can be called over and over without side effects: it uses an idempotent method,
+// and isn't an auth endpoint (such as login or password reset).
+func (test ApiTest) LoadSafe() bool {
+	uri, method := test.URI, strings.ToUpper(test.Method)
+	if test.Endpoint != "" {
+		apiDef := ApiEndpoints[test.Endpoint]
+		uri, method = apiDef.URI, apiDef.Method
+	}
+	if method == POST || method == PATCH {
+		return false
+	}
+	for _, apiDef := range authEndpoints {
+		if uri == apiDef.URI {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterLoadSafe keeps only the tests that are safe to load test (see ApiTest.LoadSafe), and gives the names of the others
+func (suite *ApiTestSuite) FilterLoadSafe() (skipped []string) {
+	tests := make([]ApiTest, 0, len(suite.Tests))
+	for _, test := range suite.Tests {
+		if test.LoadSafe() {
+			tests = append(tests, test)
+		} else {
+			skipped = append(skipped, test.Name)
+		}
+	}
+	suite.Tests = tests
+	return skipped
+}
+
+var varPattern = regexp.MustCompile(`{{\s*([\w.-]+)\s*}}`)
+
+// expand replaces {{name}} variables in s with the suite's variables, or the base URLs of the client
+func (suite ApiTestSuite) expand(client *Client, s string) string {
+	return varPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := varPattern.FindStringSubmatch(match)[1]
+		if name == "base_url" {
+			return client.BaseURL
+		}
+		if name == "console_url" {
+			return client.ConsoleBaseURL
+		}
+		if val, exists := suite.Vars[name]; exists {
+			return val
+		}
+		return match
+	})
+}
+
+// expandAny replaces variables in all strings found in a value decoded from YAML
+func (suite ApiTestSuite) expandAny(client *Client, val interface{}) interface{} {
+	switch v := val.(type) {
+	case string:
+		return suite.expand(client, v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			out[key] = suite.expandAny(client, elem)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = suite.expandAny(client, elem)
+		}
+		return out
+	}
+	return val
+}
+
+// resolve gives the API definition and request details for a test, with all variables expanded
+func (suite ApiTestSuite) resolve(client *Client, test ApiTest) (ApiEndpoint, ApiTest) {
+	var apiDef ApiEndpoint
+	if test.Endpoint != "" {
+		apiDef = *ApiEndpoints[test.Endpoint]
+	} else {
+		apiDef = ApiEndpoint{
+			URI:          test.URI,
+			DisplayURI:   test.URI,
+			Method:       strings.ToUpper(test.Method),
+			RequireToken: true,
+		}
+	}
+	if test.Auth != nil {
+		apiDef.RequireToken = *test.Auth
+	}
+
+	if len(test.PathParams) > 0 {
+		formatURI := make([]any, len(test.PathParams))
+		for i, param := range test.PathParams {
+			formatURI[i] = suite.expand(client, param)
+		}
+		apiDef.URI = fmt.Sprintf(apiDef.URI, formatURI...)
+	}
+	if test.Query != nil {
+		query := make(map[string]string, len(test.Query))
+		for key, val := range test.Query {
+			query[key] = suite.expand(client, val)
+		}
+		test.Query = query
+	}
+	if test.Headers != nil {
+		headers := make(map[string]string, len(test.Headers))
+		for key, val := range test.Headers {
+			headers[key] = suite.expand(client, val)
+		}
+		test.Headers = headers
+	}
+	test.Payload = suite.expandAny(client, test.Payload)
+	if test.Assert.JsonEquals != nil {
+		test.Assert.JsonEquals = suite.expandAny(client, test.Assert.JsonEquals).(map[string]interface{})
+	}
+	bodyContains := make([]string, len(test.Assert.BodyContains))
+	for i, s := range test.Assert.BodyContains {
+		bodyContains[i] = suite.expand(client, s)
+	}
+	test.Assert.BodyContains = bodyContains
+
+	return apiDef, test
+}
+
+// request builds the request for a resolved test
+func (test ApiTest) request(apiDef ApiEndpoint) (ApiRequest, error) {
+	req := ApiRequest{
+		Method:  apiDef.Method,
+		URI:     apiDef.URI,
+		Headers: test.Headers,
+		Query:   test.Query,
+	}
+	if test.Payload != nil && apiDef.Method != GET {
+		body, err := payloadToJson(test.Payload)
+		if err != nil {
+			return ApiRequest{}, fmt.Errorf("%s: %w", test.Name, err)
+		}
+		req.Body = body
+	}
+	return req, nil
+}
+
+// Eval checks a response against the assertions. latency is how long the request took.
+func (a ApiAssertions) Eval(status int, body []byte, latency time.Duration) error {
+	if a.Status != 0 && status != a.Status {
+		return fmt.Errorf("expected status %v, got %v", a.Status, status)
+	}
+	if a.MaxLatencyMs != 0 && latency.Milliseconds() > a.MaxLatencyMs {
+		return fmt.Errorf("took %v ms, which is over the max latency of %v ms", latency.Milliseconds(), a.MaxLatencyMs)
+	}
+	for _, s := range a.BodyContains {
+		if !strings.Contains(string(body), s) {
+			return fmt.Errorf("response body does not contain %q", s)
+		}
+	}
+
+	if len(a.JsonExists) == 0 && len(a.JsonEquals) == 0 {
+		return nil
+	}
+	var jsonData interface{}
+	if err := json.Unmarshal(body, &jsonData); err != nil {
+		return err
+	}
+	for _, path := range a.JsonExists {
+		if len(lookupJsonPath(jsonData, path)) == 0 {
+			return fmt.Errorf("missing %s in response", path)
+		}
+	}
+	for path, expected := range a.JsonEquals {
+		values := lookupJsonPath(jsonData, path)
+		if len(values) == 0 {
+			return fmt.Errorf("missing %s in response", path)
+		}
+		matched := false
+		for _, val := range values {
+			// compare as strings, since YAML and JSON decode numbers differently
+			if fmt.Sprint(val) == fmt.Sprint(expected) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s is %v, expected %v", path, values[0], expected)
+		}
+	}
+	return nil
+}
+
+// lookupJsonPath gives all values found at the given path in decoded JSON.
+// More than one value may be found when the path includes "*".
+func lookupJsonPath(data interface{}, path string) []interface{} {
+	current := []interface{}{data}
+	for _, key := range strings.Split(path, ".") {
+		next := make([]interface{}, 0)
+		for _, val := range current {
+			switch v := val.(type) {
+			case map[string]interface{}:
+				if elem, exists := v[key]; exists {
+					next = append(next, elem)
+				}
+			case []interface{}:
+				if key == "*" {
+					next = append(next, v...)
+					continue
+				}
+				index, err := strconv.Atoi(key)
+				if err == nil && index >= 0 && index < len(v) {
+					next = append(next, v[index])
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
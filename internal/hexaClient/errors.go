@@ -0,0 +1,99 @@
+package hexaclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// error codes that Hexabase gives in the "error_code" field of an error response
+const (
+	ERROR_CODE_NOT_FOUND    = "NOT_FOUND"
+	ERROR_CODE_SYSTEM_ERROR = "SYSTEM_ERROR"
+	ERROR_CODE_UNAUTHORIZED = "UNAUTHORIZED"
+	ERROR_CODE_FORBIDDEN    = "FORBIDDEN"
+)
+
+// APIError is an error response from a Hexabase API.
+// Some APIs respond with a 200 status and an error in the body, so StatusCode isn't always an error status.
+type APIError struct {
+	StatusCode int
+	ErrorCode  string // the "error_code" field of the response, if any
+	Message    string // the "error" (or "message") field of the response, if any
+	Method     string
+	URI        string
+	Body       []byte // the raw response body
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s", e.Method, e.URI)
+	if e.StatusCode >= 400 {
+		msg += fmt.Sprintf(": %v %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.ErrorCode != "" {
+		msg += ": " + e.ErrorCode
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is an API error for something that doesn't exist
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound || apiErr.ErrorCode == ERROR_CODE_NOT_FOUND
+}
+
+// IsUnauthorized reports whether err is an API error for a request the user isn't logged in for, or isn't allowed to make
+func IsUnauthorized(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized, apiErr.StatusCode == http.StatusForbidden:
+		return true
+	case apiErr.ErrorCode == ERROR_CODE_UNAUTHORIZED, apiErr.ErrorCode == ERROR_CODE_FORBIDDEN:
+		return true
+	}
+	return false
+}
+
+// checkResponse gives an APIError if the response is an error: either an error status,
+// or a JSON object with an "error_code" or "error" field.
+func checkResponse(req ApiRequest, resp ApiResponse) error {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     req.Method,
+		URI:        req.URI,
+		Body:       resp.Body,
+	}
+
+	var fields struct {
+		ErrorCode string `json:"error_code"`
+		Error     any    `json:"error"`
+		Message   any    `json:"message"`
+	}
+	trimmed := bytes.TrimSpace(resp.Body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		// a body that isn't valid JSON is left to the caller to deal with
+		_ = json.Unmarshal(trimmed, &fields)
+	}
+	apiErr.ErrorCode = fields.ErrorCode
+	if msg, ok := fields.Error.(string); ok {
+		apiErr.Message = msg
+	} else if msg, ok := fields.Message.(string); ok && resp.StatusCode >= 400 {
+		apiErr.Message = msg
+	}
+
+	if resp.StatusCode >= 400 || apiErr.ErrorCode != "" || apiErr.Message != "" {
+		return apiErr
+	}
+	return nil
+}
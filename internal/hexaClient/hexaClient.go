@@ -2,115 +2,338 @@ package hexaclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/bwebb-hx/hxutil/internal/utils"
 )
 
-var baseURL = "https://api.hexabase.com"
+const (
+	DEFAULT_BASE_URL         = "https://api.hexabase.com"
+	DEFAULT_CONSOLE_BASE_URL = "https://app.hexabase.com"
 
-var Token string = ""
+	// URIs starting with this are resolved against the console base URL instead of the API base URL.
+	// the unofficial (UN_) APIs are served by the management console.
+	CONSOLE_PREFIX = "@console"
+)
+
+// DEFAULT_TIMEOUT is how long a single attempt at a request can take, when no timeout is set
+const DEFAULT_TIMEOUT = 60 * time.Second
 
-var httpClient = &http.Client{
-	Timeout: 60 * time.Second,
+// settings given to new clients
+var (
+	defaultTimeout    = DEFAULT_TIMEOUT
+	defaultTokenStore TokenStore
+)
+
+// SetDefaultTimeout changes how long a single attempt at a request can take, for clients created after this. 0 means no time limit.
+func SetDefaultTimeout(d time.Duration) {
+	defaultTimeout = max(d, 0)
 }
 
-func SetBaseUrl(url string) {
-	baseURL = url
+// SendFunc sends a single HTTP request
+type SendFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the sending of each attempt at a request, such as to log or change requests.
+type Middleware func(next SendFunc) SendFunc
+
+// Client calls the APIs of a single hexabase environment, as a single user.
+// Clients don't share any state, so several can be used at once, such as to work with projects that need different logins.
+type Client struct {
+	BaseURL        string // API base URL
+	ConsoleBaseURL string // management console base URL, which the unofficial APIs are called with
+	Token          string // auth token sent with every request, set by logging in
+
+	HTTPClient  *http.Client
+	RetryPolicy RetryPolicy
+	Timeout     time.Duration // how long a single attempt at a request can take. 0 means no time limit.
+	TokenStore  TokenStore    // where login tokens are cached. nil disables caching.
+
+	middleware []Middleware
 }
 
-func PostApi(uri string, body []byte) ([]byte, error) {
-	if !strings.Contains(uri, "http") {
-		uri = fmt.Sprintf("%s%s", baseURL, uri)
+// NewClient gives a client for the given base URLs, with the default settings.
+// An empty url uses the default one for hexabase.
+func NewClient(baseURL, consoleBaseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DEFAULT_BASE_URL
 	}
-
-	req, err := http.NewRequest("POST", uri, bytes.NewReader(body))
-	if err != nil {
-		return nil, err
+	if consoleBaseURL == "" {
+		consoleBaseURL = DEFAULT_CONSOLE_BASE_URL
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return &Client{
+		BaseURL:        strings.TrimSuffix(baseURL, "/"),
+		ConsoleBaseURL: strings.TrimSuffix(consoleBaseURL, "/"),
+		// requests are bounded by their context, which is given the timeout, instead of a timeout on the client
+		HTTPClient:  &http.Client{},
+		RetryPolicy: defaultRetryPolicy,
+		Timeout:     defaultTimeout,
+		TokenStore:  defaultTokenStore,
+	}
+}
 
-	if Token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", Token))
+// Use adds middleware that every attempt at a request goes through. The first middleware added is the outermost.
+func (c *Client) Use(middleware ...Middleware) {
+	c.middleware = append(c.middleware, middleware...)
+}
+
+// resolveURI prefixes the base URL to the given URI, unless it's already a full URL
+func (c *Client) resolveURI(uri string) string {
+	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+		return uri
 	}
+	if strings.HasPrefix(uri, CONSOLE_PREFIX) {
+		return c.ConsoleBaseURL + strings.TrimPrefix(uri, CONSOLE_PREFIX)
+	}
+	return c.BaseURL + uri
+}
 
-	resp, err := httpClient.Do(req)
+// ApiRequest describes a request to a Hexabase API
+type ApiRequest struct {
+	Method     string
+	URI        string            // either a path, which is appended to the base URL, or a full URL
+	Headers    map[string]string // Content-Type defaults to JSON when a body is given
+	Query      map[string]string
+	Body       []byte
+	AllowRetry bool          // retry the request even if its method isn't idempotent (see RetryPolicy)
+	Timeout    time.Duration // how long a single attempt can take. defaults to the timeout of the client.
+}
+
+// ApiResponse is the response of an API call, along with timing details
+type ApiResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	TTFB       time.Duration // time to first byte of the response (of the last attempt)
+	Duration   time.Duration // total time of the last attempt, including reading the body
+	Retries    int           // number of times the request was retried
+}
+
+// CallApi sends a request with the given method, query params and (JSON) body, and returns the response status code and body.
+// An APIError is given if the response is an error.
+func (c *Client) CallApi(ctx context.Context, method, uri string, queryParams map[string]string, body []byte) (int, []byte, error) {
+	resp, err := c.CallApiTimed(ctx, method, uri, queryParams, body)
+	return resp.StatusCode, resp.Body, err
+}
+
+// CallApiTimed is the same as CallApi, but also gives timing details of the request.
+// Timing details are set even if an error occurs.
+func (c *Client) CallApiTimed(ctx context.Context, method, uri string, queryParams map[string]string, body []byte) (ApiResponse, error) {
+	return c.doChecked(ctx, ApiRequest{
+		Method: method,
+		URI:    uri,
+		Query:  queryParams,
+		Body:   body,
+	})
+}
+
+// doChecked is Do, but gives an APIError if the response is an error
+func (c *Client) doChecked(ctx context.Context, apiReq ApiRequest) (ApiResponse, error) {
+	resp, err := c.Do(ctx, apiReq)
 	if err != nil {
-		return []byte{}, err
+		return resp, err
+	}
+	return resp, checkResponse(apiReq, resp)
+}
+
+// Do sends a request, and returns the response along with timing details.
+// The client's token is sent if one is set, unless an Authorization header is given.
+//
+// Transport errors and rate limit or gateway statuses are retried according to the client's retry policy.
+// Other error statuses are not treated as errors; use CallApi for that.
+// Once ctx is done, the request in flight is cancelled and no more retries are made.
+func (c *Client) Do(ctx context.Context, apiReq ApiRequest) (ApiResponse, error) {
+	policy := c.RetryPolicy.withDefaults()
+	if !policy.canRetry(apiReq) {
+		policy.MaxRetries = 0
 	}
-	defer resp.Body.Close()
 
-	return io.ReadAll(resp.Body)
+	for retry := 0; ; retry++ {
+		resp, err := c.send(ctx, apiReq)
+		resp.Retries = retry
+		if retry >= policy.MaxRetries || ctx.Err() != nil || !shouldRetry(resp, err) {
+			return resp, err
+		}
+		wait := time.NewTimer(policy.delay(retry+1, resp.Header))
+		select {
+		case <-ctx.Done():
+			wait.Stop()
+			return resp, err
+		case <-wait.C:
+		}
+	}
 }
 
-func GetApi(uri string, queryParams map[string]string) ([]byte, error) {
-	if !strings.Contains(uri, "http") {
-		uri = fmt.Sprintf("%s%s", baseURL, uri)
+// send makes a single attempt at a request
+func (c *Client) send(ctx context.Context, apiReq ApiRequest) (ApiResponse, error) {
+	attemptTimeout := apiReq.Timeout
+	if attemptTimeout <= 0 {
+		attemptTimeout = c.Timeout
 	}
+	if attemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, attemptTimeout)
+		defer cancel()
+	}
+
+	uri := c.resolveURI(apiReq.URI)
 
-	if queryParams != nil {
-		params := make([]string, 0)
-		uri += "?"
-		for param, value := range queryParams {
-			params = append(params, fmt.Sprintf("%s=%s", param, value))
+	if len(apiReq.Query) > 0 {
+		params := url.Values{}
+		for param, value := range apiReq.Query {
+			params.Set(param, value)
 		}
-		uri += strings.Join(params, "&")
+		sep := "?"
+		if strings.Contains(uri, "?") {
+			sep = "&"
+		}
+		uri += sep + params.Encode()
 	}
 
-	req, err := http.NewRequest("GET", uri, nil)
+	var bodyReader io.Reader
+	if apiReq.Body != nil {
+		bodyReader = bytes.NewReader(apiReq.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, apiReq.Method, uri, bodyReader)
 	if err != nil {
-		return nil, err
+		return ApiResponse{}, err
+	}
+	if apiReq.Body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
-	if Token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", Token))
+	if c.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
+	}
+	for key, value := range apiReq.Headers {
+		req.Header.Set(key, value)
 	}
 
-	resp, err := httpClient.Do(req)
+	apiResp := ApiResponse{}
+	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			apiResp.TTFB = time.Since(start)
+		},
+	}))
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	send := httpClient.Do
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		send = c.middleware[i](send)
+	}
+	resp, err := send(req)
 	if err != nil {
-		return nil, err
+		apiResp.Duration = time.Since(start)
+		return apiResp, err
 	}
 	defer resp.Body.Close()
 
-	return io.ReadAll(resp.Body)
+	apiResp.StatusCode = resp.StatusCode
+	apiResp.Header = resp.Header
+	apiResp.Body, err = io.ReadAll(resp.Body)
+	apiResp.Duration = time.Since(start)
+	return apiResp, err
+}
+
+// PostApi sends a POST request with a JSON body. An APIError is given if the response is an error.
+func (c *Client) PostApi(ctx context.Context, uri string, body []byte) ([]byte, error) {
+	_, resp, err := c.CallApi(ctx, POST, uri, nil, body)
+	return resp, err
+}
+
+// PostMultipartApi sends a multipart form POST request, with the given form fields and a single file.
+// An APIError is given if the response is an error.
+func (c *Client) PostMultipartApi(ctx context.Context, uri string, fields map[string]string, fileField, fileName string, fileContents []byte) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for field, value := range fields {
+		if err := writer.WriteField(field, value); err != nil {
+			return nil, err
+		}
+	}
+	fileWriter, err := writer.CreateFormFile(fileField, fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fileWriter.Write(fileContents); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doChecked(ctx, ApiRequest{
+		Method:  POST,
+		URI:     uri,
+		Headers: map[string]string{"Content-Type": writer.FormDataContentType()},
+		Body:    body.Bytes(),
+	})
+	return resp.Body, err
+}
+
+// GetApi sends a GET request with the given query params. An APIError is given if the response is an error.
+func (c *Client) GetApi(ctx context.Context, uri string, queryParams map[string]string) ([]byte, error) {
+	_, resp, err := c.CallApi(ctx, GET, uri, queryParams, nil)
+	return resp, err
 }
 
-func Login(email, password string) string {
-	loginResp, err := PostApi(LoginAPI.URI, payloadToJson(LoginPayload{
+// LoginFresh always logs in with the given credentials (ignoring any cached token), and caches the new token.
+// Cached tokens are only used for passwords that are already known to be right (see config.User.Login),
+// so that a password given explicitly is always checked.
+func (c *Client) LoginFresh(ctx context.Context, email, password string) (string, error) {
+	payload, err := json.Marshal(LoginPayload{
 		Email:    email,
 		Password: password,
-	}))
+	})
+	if err != nil {
+		return "", err
+	}
+	loginResp, err := c.PostApi(ctx, LoginAPI.URI, payload)
 	if err != nil {
-		utils.Fatal("error occurred during login", err.Error())
+		return "", fmt.Errorf("error occurred during login: %w", err)
 	}
 
 	var responseJson map[string]interface{}
 	err = json.Unmarshal(loginResp, &responseJson)
 	if err != nil {
-		utils.Fatal("failed to unmarshal login API response", err.Error())
+		return "", fmt.Errorf("failed to unmarshal login API response: %w", err)
 	}
 
-	token, exists := responseJson["token"]
-	if !exists {
-		utils.Fatal("login failed", "failed to get token from response")
+	token, ok := responseJson["token"].(string)
+	if !ok {
+		return "", fmt.Errorf("failed to get token from response")
 	}
-
-	Token = token.(string)
-	if Token == "" {
-		utils.Fatal("login failed", "token is unexpectedly empty")
+	if token == "" {
+		return "", fmt.Errorf("token is unexpectedly empty")
 	}
-	return Token
+	c.Token = token
+	c.cacheToken(email, token)
+	return token, nil
 }
 
-func PromptLogin() string {
-	fmt.Println("enter login credentials.")
-	username := utils.GetInput("email")
-	password := utils.GetInput("password")
+func (c *Client) PromptLogin(ctx context.Context) (string, error) {
+	fmt.Fprintln(os.Stderr, "enter login credentials.")
+	username, err := utils.GetInput("email")
+	if err != nil {
+		return "", err
+	}
+	password, err := utils.GetInput("password")
+	if err != nil {
+		return "", err
+	}
 
-	return Login(username, password)
+	return c.LoginFresh(ctx, username, password)
 }
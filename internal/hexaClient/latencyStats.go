@@ -0,0 +1,79 @@
+package hexaclient
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// LatencyStats summarizes the latencies of repeated calls to an API
+type LatencyStats struct {
+	N         int // number of calls
+	Errors    int // calls that failed, either in transport or in assertions
+	Min       time.Duration
+	Max       time.Duration
+	Mean      time.Duration
+	StdDev    time.Duration
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+	MeanTTFB  time.Duration // mean time to first byte
+	ErrorRate float64       // ratio of calls that failed, from 0 to 1
+}
+
+// computeLatencyStats calculates stats from the latency and time to first byte of each call.
+func computeLatencyStats(latencies []time.Duration, ttfbs []time.Duration, errors int) LatencyStats {
+	stats := LatencyStats{
+		N:      len(latencies),
+		Errors: errors,
+	}
+	if len(latencies) == 0 {
+		return stats
+	}
+	stats.ErrorRate = float64(errors) / float64(len(latencies))
+
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted)-1]
+	stats.P50 = percentile(sorted, 50)
+	stats.P90 = percentile(sorted, 90)
+	stats.P99 = percentile(sorted, 99)
+
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	stats.Mean = total / time.Duration(len(latencies))
+
+	var variance float64
+	for _, latency := range latencies {
+		d := float64(latency - stats.Mean)
+		variance += d * d
+	}
+	stats.StdDev = time.Duration(math.Sqrt(variance / float64(len(latencies))))
+
+	if len(ttfbs) > 0 {
+		var totalTTFB time.Duration
+		for _, ttfb := range ttfbs {
+			totalTTFB += ttfb
+		}
+		stats.MeanTTFB = totalTTFB / time.Duration(len(ttfbs))
+	}
+
+	return stats
+}
+
+// percentile gives the p-th percentile (nearest rank) of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
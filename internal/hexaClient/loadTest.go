@@ -0,0 +1,284 @@
+package hexaclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LoadTestOptions controls how hard, and for how long, endpoints are called during a load test
+type LoadTestOptions struct {
+	Concurrency int           // number of workers calling APIs at the same time
+	RPS         float64       // target requests per second, across all workers. 0 means unlimited.
+	Duration    time.Duration // how long to run the load test for, including ramp up
+	RampUp      time.Duration // time taken to reach full concurrency and rate
+}
+
+// how often rate limited load tests give out the requests they have earned
+const rateTick = 10 * time.Millisecond
+
+// upper bounds (in ms) of the latency histogram buckets. the last bucket has no upper bound.
+var histogramBucketsMs = []int64{50, 100, 200, 300, 500, 1000, 2000, 5000}
+
+// loadTestEndpoint collects the outcome of all calls made to a single endpoint during a load test
+type loadTestEndpoint struct {
+	mu sync.Mutex
+
+	apiDef         ApiEndpoint
+	requests       int
+	pass           int
+	latencies      []time.Duration
+	ttfbs          []time.Duration
+	statusCodes    map[int]int
+	transportErrs  int
+	assertionFails int
+}
+
+func (e *loadTestEndpoint) record(resp ApiResponse, callErr error, evalErr error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests++
+	e.latencies = append(e.latencies, resp.Duration)
+	if callErr != nil {
+		e.transportErrs++
+		return
+	}
+	e.ttfbs = append(e.ttfbs, resp.TTFB)
+	e.statusCodes[resp.StatusCode]++
+	if evalErr != nil {
+		e.assertionFails++
+		return
+	}
+	e.pass++
+}
+
+// RunLoadTest calls the tests of a suite repeatedly and concurrently for a set duration, and prints
+// the throughput, latency histograms and errors of each endpoint. Tests are taken in turn by each worker.
+// If ctx is cancelled, the load test stops early and the results so far are shown.
+func RunLoadTest(ctx context.Context, client *Client, suite *ApiTestSuite, opts LoadTestOptions) error {
+	if len(suite.Tests) == 0 {
+		fmt.Println("no tests to run")
+		return nil
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+
+	requireToken := false
+	apiDefs := make([]ApiEndpoint, len(suite.Tests))
+	tests := make([]ApiTest, len(suite.Tests))
+	requests := make([]ApiRequest, len(suite.Tests))
+	endpoints := make([]*loadTestEndpoint, len(suite.Tests))
+	for i, test := range suite.Tests {
+		apiDefs[i], tests[i] = suite.resolve(client, test)
+		requireToken = requireToken || apiDefs[i].RequireToken
+		req, err := tests[i].request(apiDefs[i])
+		if err != nil {
+			return err
+		}
+		requests[i] = req
+		endpoints[i] = &loadTestEndpoint{
+			apiDef:      apiDefs[i],
+			statusCodes: make(map[int]int),
+		}
+	}
+	if requireToken {
+		if _, err := client.LoginFresh(ctx, suite.expand(client, suite.Login.Email), suite.expand(client, suite.Login.Password)); err != nil {
+			return fmt.Errorf("failed to login: %w", err)
+		}
+		fmt.Println("(login succeeded)")
+	}
+
+	fmt.Printf("load testing %v endpoints: concurrency %v, rate %s, duration %s, ramp up %s\n",
+		len(tests), opts.Concurrency, rateString(opts.RPS), opts.Duration, opts.RampUp)
+
+	// requests in flight when the duration is up are allowed to finish, so only the workers are stopped by it
+	runCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	start := time.Now()
+
+	// during ramp up, the fraction of full load that should be applied
+	rampFactor := func() float64 {
+		if opts.RampUp <= 0 {
+			return 1
+		}
+		return min(1, float64(time.Since(start))/float64(opts.RampUp))
+	}
+
+	// when rate limited, each request must first take a ticket.
+	// tickets are earned at the current rate every tick, so the rate follows the ramp up (and can be below 1 rps).
+	var tickets chan struct{}
+	if opts.RPS > 0 {
+		tickets = make(chan struct{})
+		go func() {
+			ticker := time.NewTicker(rateTick)
+			defer ticker.Stop()
+			earned := 0.0
+			last := time.Now()
+			for {
+				select {
+				case <-runCtx.Done():
+					return
+				case now := <-ticker.C:
+					rate := opts.RPS * rampFactor()
+					earned += rate * now.Sub(last).Seconds()
+					last = now
+					// time spent waiting for workers isn't made up for with a burst
+					earned = min(earned, max(1, rate*rateTick.Seconds()))
+				}
+				for ; earned >= 1; earned-- {
+					select {
+					case tickets <- struct{}{}:
+					case <-runCtx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(opts.Concurrency)
+	for worker := 0; worker < opts.Concurrency; worker++ {
+		go func() {
+			defer wg.Done()
+
+			// workers are started gradually over the ramp up period
+			delay := time.Duration(float64(opts.RampUp) * float64(worker) / float64(opts.Concurrency))
+			select {
+			case <-runCtx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			for runCtx.Err() == nil {
+				if tickets != nil {
+					select {
+					case <-runCtx.Done():
+						return
+					case <-tickets:
+					}
+				}
+
+				i := int(next.Add(1)-1) % len(tests)
+				resp, err := client.Do(ctx, requests[i])
+				if ctx.Err() != nil {
+					// interrupted, so the call didn't really fail
+					return
+				}
+				var evalErr error
+				if err == nil {
+					evalErr = tests[i].Assert.Eval(resp.StatusCode, resp.Body, resp.Duration)
+				}
+				endpoints[i].record(resp, err, evalErr)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	printLoadTestResults(endpoints, elapsed)
+	return nil
+}
+
+func rateString(rps float64) string {
+	if rps <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%v req/s", rps)
+}
+
+func printLoadTestResults(endpoints []*loadTestEndpoint, elapsed time.Duration) {
+	totalRequests, totalPass := 0, 0
+	for _, endpoint := range endpoints {
+		totalRequests += endpoint.requests
+		totalPass += endpoint.pass
+	}
+
+	fmt.Println("\n== LOAD TEST RESULTS ==")
+	fmt.Printf("duration: %s  requests: %v  throughput: %.1f req/s  success: %v/%v\n",
+		elapsed.Round(time.Millisecond), totalRequests, float64(totalRequests)/elapsed.Seconds(), totalPass, totalRequests)
+
+	for _, endpoint := range endpoints {
+		stats := computeLatencyStats(endpoint.latencies, endpoint.ttfbs, endpoint.requests-endpoint.pass)
+
+		c := passColor
+		if endpoint.pass < endpoint.requests {
+			c = failColor
+		}
+		fmt.Println()
+		c.Printf("%s %s  %v/%v passed  %.1f req/s\n", endpoint.apiDef.Method, endpoint.apiDef.DisplayURI, endpoint.pass, endpoint.requests, float64(endpoint.requests)/elapsed.Seconds())
+		if endpoint.requests == 0 {
+			continue
+		}
+		fmt.Printf("    min %s  p50 %s  p90 %s  p99 %s  max %s  σ %v  ttfb %s  err %.0f%%\n",
+			speedometer(stats.Min.Milliseconds()),
+			speedometer(stats.P50.Milliseconds()),
+			speedometer(stats.P90.Milliseconds()),
+			speedometer(stats.P99.Milliseconds()),
+			speedometer(stats.Max.Milliseconds()),
+			stats.StdDev.Milliseconds(),
+			speedometer(stats.MeanTTFB.Milliseconds()),
+			stats.ErrorRate*100,
+		)
+
+		printHistogram(endpoint.latencies)
+
+		// error breakdown
+		codes := make([]int, 0, len(endpoint.statusCodes))
+		for code := range endpoint.statusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		breakdown := make([]string, 0)
+		for _, code := range codes {
+			breakdown = append(breakdown, fmt.Sprintf("%v: %v", code, endpoint.statusCodes[code]))
+		}
+		fmt.Printf("    status codes: %s\n", strings.Join(breakdown, "  "))
+		if endpoint.transportErrs > 0 || endpoint.assertionFails > 0 {
+			failColor.Printf("    transport errors: %v  assertion failures: %v\n", endpoint.transportErrs, endpoint.assertionFails)
+		}
+	}
+}
+
+// printHistogram shows how latencies are distributed across the histogram buckets
+func printHistogram(latencies []time.Duration) {
+	const barWidth = 40
+
+	counts := make([]int, len(histogramBucketsMs)+1)
+	for _, latency := range latencies {
+		ms := latency.Milliseconds()
+		bucket := len(histogramBucketsMs)
+		for i, upper := range histogramBucketsMs {
+			if ms <= upper {
+				bucket = i
+				break
+			}
+		}
+		counts[bucket]++
+	}
+	maxCount := 0
+	for _, count := range counts {
+		maxCount = max(maxCount, count)
+	}
+
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		label := ""
+		if i < len(histogramBucketsMs) {
+			label = fmt.Sprintf("<= %s ms", speedometerPad(histogramBucketsMs[i], 5))
+		} else {
+			label = fmt.Sprintf(" > %s ms", speedometerPad(histogramBucketsMs[len(histogramBucketsMs)-1], 5))
+		}
+		bar := strings.Repeat("#", max(1, count*barWidth/maxCount))
+		fmt.Printf("    %s |%-*s %v\n", label, barWidth, bar, count)
+	}
+}
@@ -0,0 +1,116 @@
+package hexaclient
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_MAX_RETRIES = 3
+	DEFAULT_BASE_DELAY  = 500 * time.Millisecond
+	DEFAULT_MAX_DELAY   = 30 * time.Second
+)
+
+// RetryPolicy controls how requests that fail with a transport error, or a rate limit or gateway status, are retried
+type RetryPolicy struct {
+	MaxRetries         int           // retries after the first attempt. 0 disables retries.
+	BaseDelay          time.Duration // delay before the first retry. doubles for each retry after that.
+	MaxDelay           time.Duration // longest delay between attempts, including ones asked for with Retry-After
+	RetryNonIdempotent bool          // also retry methods such as POST, which may not be safe to send twice
+}
+
+// DefaultRetryPolicy gives the retry policy used when none is set
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: DEFAULT_MAX_RETRIES,
+		BaseDelay:  DEFAULT_BASE_DELAY,
+		MaxDelay:   DEFAULT_MAX_DELAY,
+	}
+}
+
+var defaultRetryPolicy = DefaultRetryPolicy()
+
+// SetDefaultRetryPolicy changes how requests are retried, for clients created after this. Delays that aren't set use the defaults.
+func SetDefaultRetryPolicy(policy RetryPolicy) {
+	defaultRetryPolicy = policy.withDefaults()
+}
+
+// withDefaults fills in the delays that aren't set
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxRetries < 0 {
+		p.MaxRetries = 0
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DEFAULT_BASE_DELAY
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DEFAULT_MAX_DELAY
+	}
+	return p
+}
+
+// statuses that mean the request can be tried again later
+var retryableStatuses = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// idempotent methods can be sent more than once without changing the result
+func isIdempotent(method string) bool {
+	switch strings.ToUpper(method) {
+	case GET, "HEAD", "OPTIONS", PUT, DELETE:
+		return true
+	}
+	return false
+}
+
+// canRetry reports whether a request may be retried under the policy, regardless of how it failed
+func (p RetryPolicy) canRetry(req ApiRequest) bool {
+	return isIdempotent(req.Method) || req.AllowRetry || p.RetryNonIdempotent
+}
+
+// shouldRetry reports whether an attempt failed in a way that is worth retrying
+func shouldRetry(resp ApiResponse, err error) bool {
+	if err != nil {
+		return true
+	}
+	return retryableStatuses[resp.StatusCode]
+}
+
+// delay gives how long to wait before the given retry (starting at 1).
+// A Retry-After header is respected, as long as it's within the max delay; otherwise exponential backoff with jitter is used.
+func (p RetryPolicy) delay(retry int, header http.Header) time.Duration {
+	if wait, ok := retryAfter(header); ok {
+		return min(wait, p.MaxDelay)
+	}
+	backoff := p.BaseDelay << (retry - 1)
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	// wait somewhere between half and all of the backoff, so that concurrent requests don't retry in lockstep
+	half := backoff / 2
+	if half <= 0 {
+		return backoff
+	}
+	return half + rand.N(half)
+}
+
+// retryAfter parses a Retry-After header, which is either a number of seconds or an HTTP date
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,77 @@
+package hexaclient
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/bwebb-hx/hxutil/internal/utils"
+)
+
+// tokens that expire within this margin are treated as already expired, so they don't expire mid-command
+const tokenExpiryMargin = time.Minute
+
+// TokenStore persists login tokens between runs, per base URL and user
+type TokenStore interface {
+	Get(baseURL, email string) (string, bool)
+	Set(baseURL, email, token string) error
+	Delete(baseURL, email string) error
+}
+
+// SetDefaultTokenStore sets where login tokens are cached, for clients created after this. Without one, every run has to login again.
+func SetDefaultTokenStore(store TokenStore) {
+	defaultTokenStore = store
+}
+
+// TokenExpiry gives the expiry time from the "exp" claim of a JWT. false is returned if it can't be determined.
+func TokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(*claims.Exp), 0), true
+}
+
+// tokenValid is true if the token has an expiry that hasn't been reached yet
+func tokenValid(token string) bool {
+	exp, ok := TokenExpiry(token)
+	return ok && time.Now().Add(tokenExpiryMargin).Before(exp)
+}
+
+// UseCachedToken sets the client's token to the cached token of the user, if there is one that is still valid.
+// Stale tokens are removed from the cache.
+func (c *Client) UseCachedToken(email string) bool {
+	if c.TokenStore == nil || email == "" {
+		return false
+	}
+	token, exists := c.TokenStore.Get(c.BaseURL, email)
+	if !exists {
+		return false
+	}
+	if !tokenValid(token) {
+		c.TokenStore.Delete(c.BaseURL, email)
+		return false
+	}
+	c.Token = token
+	return true
+}
+
+func (c *Client) cacheToken(email, token string) {
+	if c.TokenStore == nil {
+		return
+	}
+	if err := c.TokenStore.Set(c.BaseURL, email, token); err != nil {
+		utils.WarnTo(utils.Stderr(), "failed to cache login token", err.Error())
+	}
+}
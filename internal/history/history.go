@@ -0,0 +1,251 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwebb-hx/hxutil/internal/config"
+	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/utils"
+)
+
+// file (in the config directory) where api test runs are recorded, one JSON object per line
+const HISTORY_FILE = "api_test_history.jsonl"
+
+// Run is a single recorded run of an api test suite
+type Run struct {
+	Time        time.Time        `json:"time"`
+	Suite       string           `json:"suite"`
+	Environment string           `json:"environment,omitempty"` // name of the environment the suite was run against
+	BaseURL     string           `json:"base_url,omitempty"`    // API base URL the suite was run against
+	Results     []EndpointResult `json:"results"`
+}
+
+// SameEnvironment is true if both runs were against the same API. Latencies of different environments can't be compared.
+// Runs recorded before the environment was, only match each other.
+func (r Run) SameEnvironment(other Run) bool {
+	return r.BaseURL == other.BaseURL
+}
+
+// InEnvironmentOf keeps only the runs that were against the same API as run (see SameEnvironment)
+func InEnvironmentOf(runs []Run, run Run) []Run {
+	kept := make([]Run, 0, len(runs))
+	for _, r := range runs {
+		if r.SameEnvironment(run) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// EndpointResult is the recorded result of testing a single endpoint. Latencies are in milliseconds.
+type EndpointResult struct {
+	Endpoint string `json:"endpoint"`
+	Name     string `json:"name"`
+	N        int    `json:"n"`
+	Pass     int    `json:"pass"`
+	Fail     int    `json:"fail"`
+	MeanMs   int64  `json:"mean_ms"`
+	MinMs    int64  `json:"min_ms"`
+	MaxMs    int64  `json:"max_ms"`
+	P50Ms    int64  `json:"p50_ms"`
+	P90Ms    int64  `json:"p90_ms"`
+	P99Ms    int64  `json:"p99_ms"`
+	TTFBMs   int64  `json:"ttfb_ms"`
+	Retries  int    `json:"retries,omitempty"`
+}
+
+// PassRate is the ratio of calls that passed, from 0 to 1
+func (er EndpointResult) PassRate() float64 {
+	if er.N == 0 {
+		return 0
+	}
+	return float64(er.Pass) / float64(er.N)
+}
+
+func historyFilePath() (string, error) {
+	return config.DataFilePath(HISTORY_FILE)
+}
+
+// NewRun converts the results of an api test, run against the given environment and API base URL, into a run that can be recorded.
+func NewRun(suite, environment, baseURL string, results []hexaclient.ApiTestResult) Run {
+	run := Run{
+		Time:        time.Now(),
+		Suite:       suite,
+		Environment: environment,
+		BaseURL:     baseURL,
+		Results:     make([]EndpointResult, 0, len(results)),
+	}
+	for _, result := range results {
+		stats := result.Stats
+		run.Results = append(run.Results, EndpointResult{
+			Endpoint: result.Endpoint(),
+			Name:     result.Name,
+			N:        stats.N,
+			Pass:     result.Pass,
+			Fail:     result.Fail,
+			MeanMs:   stats.Mean.Milliseconds(),
+			MinMs:    stats.Min.Milliseconds(),
+			MaxMs:    stats.Max.Milliseconds(),
+			P50Ms:    stats.P50.Milliseconds(),
+			P90Ms:    stats.P90.Milliseconds(),
+			P99Ms:    stats.P99.Milliseconds(),
+			TTFBMs:   stats.MeanTTFB.Milliseconds(),
+			Retries:  result.Retries,
+		})
+	}
+	return run
+}
+
+// Append records a run at the end of the history file.
+func Append(run Run) error {
+	if err := config.EnsureConfigDir(); err != nil {
+		return err
+	}
+	runBytes, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	path, err := historyFilePath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(runBytes, '\n'))
+	return err
+}
+
+// Load reads all recorded runs of the given suite, oldest first. If suite is empty, runs of all suites are loaded.
+func Load(suite string) ([]Run, error) {
+	path, err := historyFilePath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Run{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	runs := make([]Run, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var run Run
+		if err := json.Unmarshal([]byte(line), &run); err != nil {
+			utils.Warn(fmt.Sprintf("skipping unreadable history entry (line %v)", lineNum), err.Error())
+			continue
+		}
+		if suite != "" && run.Suite != suite {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+	return runs, nil
+}
+
+// Regression is an endpoint that has gotten slower, or started failing more, compared to a baseline.
+type Regression struct {
+	Endpoint string
+	Metric   string
+	Baseline float64
+	Current  float64
+}
+
+func (r Regression) String() string {
+	if r.Metric == "pass rate" {
+		return fmt.Sprintf("%s: %s dropped from %.0f%% to %.0f%%", r.Endpoint, r.Metric, r.Baseline*100, r.Current*100)
+	}
+	change := (r.Current - r.Baseline) / r.Baseline * 100
+	return fmt.Sprintf("%s: %s went from %.0f ms to %.0f ms (+%.0f%%)", r.Endpoint, r.Metric, r.Baseline, r.Current, change)
+}
+
+// CompareToBaseline compares a run to the average of baseline runs, and gives all endpoints
+// whose mean or p90 latency got worse by more than thresholdPct percent, or whose pass rate dropped.
+// Baseline runs against a different environment than the current run are ignored.
+func CompareToBaseline(current Run, baseline []Run, thresholdPct float64) []Regression {
+	regressions := make([]Regression, 0)
+	baseline = InEnvironmentOf(baseline, current)
+	if len(baseline) == 0 {
+		return regressions
+	}
+
+	for _, result := range current.Results {
+		var meanSum, p90Sum, passRateSum float64
+		count := 0
+		for _, run := range baseline {
+			for _, baseResult := range run.Results {
+				if baseResult.Endpoint == result.Endpoint && baseResult.Name == result.Name {
+					meanSum += float64(baseResult.MeanMs)
+					p90Sum += float64(baseResult.P90Ms)
+					passRateSum += baseResult.PassRate()
+					count++
+				}
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		baseMean, baseP90, basePassRate := meanSum/float64(count), p90Sum/float64(count), passRateSum/float64(count)
+
+		limit := 1 + thresholdPct/100
+		if baseMean > 0 && float64(result.MeanMs) > baseMean*limit {
+			regressions = append(regressions, Regression{Endpoint: result.Endpoint, Metric: "mean latency", Baseline: baseMean, Current: float64(result.MeanMs)})
+		}
+		if baseP90 > 0 && float64(result.P90Ms) > baseP90*limit {
+			regressions = append(regressions, Regression{Endpoint: result.Endpoint, Metric: "p90 latency", Baseline: baseP90, Current: float64(result.P90Ms)})
+		}
+		if result.PassRate() < basePassRate {
+			regressions = append(regressions, Regression{Endpoint: result.Endpoint, Metric: "pass rate", Baseline: basePassRate, Current: result.PassRate()})
+		}
+	}
+	return regressions
+}
+
+// Sparkline draws values as a small bar chart, such as "▁▃▅█"
+func Sparkline(values []int64) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	if len(values) == 0 {
+		return ""
+	}
+	minVal, maxVal := values[0], values[0]
+	for _, v := range values {
+		minVal = min(minVal, v)
+		maxVal = max(maxVal, v)
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		i := 0
+		if maxVal > minVal {
+			i = int(float64(v-minVal) / float64(maxVal-minVal) * float64(len(bars)-1))
+		}
+		sb.WriteRune(bars[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+package ignore
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Matcher decides whether paths should be ignored, using patterns in the same style as .gitignore:
+//
+//   - "name" matches a file or directory with that name at any depth
+//   - "dir/" only matches directories
+//   - "/name" or "a/b" is anchored to the root
+//   - "*" and "?" match within a path segment, and "**" matches across segments
+//   - "!pattern" un-ignores paths matched by an earlier pattern
+//
+// When patterns conflict, the last matching one wins.
+type Matcher struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	base    string // directory the pattern is relative to
+	source  string // the pattern as it was given
+}
+
+// New creates a matcher from a list of patterns. Blank lines and comments ("#") are skipped.
+func New(patterns []string) *Matcher {
+	m := &Matcher{}
+	m.Add(patterns...)
+	return m
+}
+
+// Add adds patterns to the matcher. They take priority over patterns added before.
+func (m *Matcher) Add(patterns ...string) {
+	m.AddRelative("", patterns...)
+}
+
+// AddRelative adds patterns that are relative to a subdirectory (given relative to the root, with "/" separators),
+// such as the patterns of a .gitignore file in that directory.
+func (m *Matcher) AddRelative(base string, patterns ...string) {
+	base = strings.Trim(path.Clean("/"+base), "/")
+	prefix := ""
+	if base != "" {
+		prefix = regexp.QuoteMeta(base) + "/"
+	}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		compiled := pattern{base: base, source: p}
+		if strings.HasPrefix(p, "!") {
+			compiled.negate = true
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			compiled.dirOnly = true
+			p = strings.TrimSuffix(p, "/")
+		}
+		// patterns with a slash are relative to the root. others match at any depth.
+		anchored := strings.Contains(p, "/")
+		p = strings.TrimPrefix(p, "/")
+		if p == "" {
+			continue
+		}
+		expr := GlobToRegexp(p)
+		if !anchored {
+			expr = "(.*/)?" + expr
+		}
+		re, err := regexp.Compile("^" + prefix + expr + "$")
+		if err != nil {
+			continue
+		}
+		compiled.re = re
+		m.patterns = append(m.patterns, compiled)
+	}
+}
+
+// Clone gives a copy of the matcher, which patterns can be added to without changing the original
+func (m *Matcher) Clone() *Matcher {
+	clone := &Matcher{}
+	if m != nil {
+		clone.patterns = append(clone.patterns, m.patterns...)
+	}
+	return clone
+}
+
+// Merge adds the patterns of another matcher, as if its root were the subdirectory base.
+// They take priority over patterns added before.
+func (m *Matcher) Merge(base string, other *Matcher) {
+	if other == nil {
+		return
+	}
+	for _, p := range other.patterns {
+		m.AddRelative(path.Join(base, p.base), p.source)
+	}
+}
+
+// Match is true if the path (relative to the root, with "/" separators) is ignored.
+// Directories that are ignored should not be walked into, since everything under them is ignored too.
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	relPath = strings.TrimPrefix(path.Clean(relPath), "/")
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(relPath) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// GlobToRegexp converts a glob pattern (with "*", "?" and "**") to a regular expression, without anchors
+func GlobToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,333 @@
+package ignore
+
+import "testing"
+
+type matchCase struct {
+	path    string
+	isDir   bool
+	ignored bool
+}
+
+func checkMatches(t *testing.T, m *Matcher, cases []matchCase) {
+	t.Helper()
+	for _, c := range cases {
+		if got := m.Match(c.path, c.isDir); got != c.ignored {
+			t.Errorf("Match(%q, isDir=%v) = %v, want %v", c.path, c.isDir, got, c.ignored)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		cases    []matchCase
+	}{
+		{
+			name:     "name matches at any depth",
+			patterns: []string{"node_modules"},
+			cases: []matchCase{
+				{"node_modules", true, true},
+				{"a/b/node_modules", true, true},
+				{"a/node_modules.js", false, false},
+				{"my_node_modules", true, false},
+			},
+		},
+		{
+			name:     "wildcards stay within a segment",
+			patterns: []string{"*.min.js", "draft?.js"},
+			cases: []matchCase{
+				{"app.min.js", false, true},
+				{"lib/app.min.js", false, true},
+				{"app.js", false, false},
+				{"draft1.js", false, true},
+				{"draft10.js", false, false},
+			},
+		},
+		{
+			name:     "leading slash anchors to the root",
+			patterns: []string{"/build"},
+			cases: []matchCase{
+				{"build", true, true},
+				{"src/build", true, false},
+			},
+		},
+		{
+			name:     "slash in the middle anchors to the root",
+			patterns: []string{"src/generated"},
+			cases: []matchCase{
+				{"src/generated", true, true},
+				{"lib/src/generated", true, false},
+			},
+		},
+		{
+			name:     "leading ** matches at any depth",
+			patterns: []string{"**/fixtures"},
+			cases: []matchCase{
+				{"fixtures", true, true},
+				{"a/fixtures", true, true},
+				{"a/b/fixtures", true, true},
+				{"a/fixtures2", true, false},
+			},
+		},
+		{
+			name:     "** in the middle matches zero or more segments",
+			patterns: []string{"a/**/b"},
+			cases: []matchCase{
+				{"a/b", true, true},
+				{"a/x/b", true, true},
+				{"a/x/y/b", true, true},
+				{"x/a/b", true, false},
+			},
+		},
+		{
+			name:     "trailing ** matches everything inside",
+			patterns: []string{"tmp/**"},
+			cases: []matchCase{
+				{"tmp/a.js", false, true},
+				{"tmp/a/b.js", false, true},
+				{"other/tmp/a.js", false, false},
+			},
+		},
+		{
+			name:     "trailing slash only matches directories",
+			patterns: []string{"out/"},
+			cases: []matchCase{
+				{"out", true, true},
+				{"a/out", true, true},
+				{"out", false, false},
+			},
+		},
+		{
+			name:     "negation un-ignores an earlier match",
+			patterns: []string{"*.js", "!keep.js"},
+			cases: []matchCase{
+				{"a.js", false, true},
+				{"keep.js", false, false},
+				{"dir/keep.js", false, false},
+			},
+		},
+		{
+			name:     "last matching pattern wins",
+			patterns: []string{"!keep.js", "*.js"},
+			cases: []matchCase{
+				{"keep.js", false, true},
+			},
+		},
+		{
+			name:     "negated directory pattern",
+			patterns: []string{"node_modules/", "!node_modules/"},
+			cases: []matchCase{
+				{"node_modules", true, false},
+			},
+		},
+		{
+			name:     "blank lines and comments are skipped",
+			patterns: []string{"", "   ", "# a.js", "b.js"},
+			cases: []matchCase{
+				{"a.js", false, false},
+				{"# a.js", false, false},
+				{"b.js", false, true},
+			},
+		},
+		{
+			name:     "regexp characters are literal",
+			patterns: []string{"a+b(1).js"},
+			cases: []matchCase{
+				{"a+b(1).js", false, true},
+				{"aab1.js", false, false},
+			},
+		},
+		{
+			name:     "paths are cleaned",
+			patterns: []string{"/build"},
+			cases: []matchCase{
+				{"/build", true, true},
+				{"./build", true, true},
+				{"x/../build", true, true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkMatches(t, New(tt.patterns), tt.cases)
+		})
+	}
+}
+
+func TestAddRelative(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		patterns []string
+		cases    []matchCase
+	}{
+		{
+			name:     "unanchored pattern matches under the base",
+			base:     "sub",
+			patterns: []string{"*.log"},
+			cases: []matchCase{
+				{"sub/a.log", false, true},
+				{"sub/x/a.log", false, true},
+				{"a.log", false, false},
+				{"other/a.log", false, false},
+			},
+		},
+		{
+			name:     "anchored pattern is relative to the base",
+			base:     "sub",
+			patterns: []string{"/build"},
+			cases: []matchCase{
+				{"sub/build", true, true},
+				{"build", true, false},
+				{"sub/x/build", true, false},
+			},
+		},
+		{
+			name:     "base is cleaned",
+			base:     "./a//b/",
+			patterns: []string{"/out"},
+			cases: []matchCase{
+				{"a/b/out", true, true},
+			},
+		},
+		{
+			name:     "empty base is the root",
+			base:     "",
+			patterns: []string{"/out"},
+			cases: []matchCase{
+				{"out", true, true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Matcher{}
+			m.AddRelative(tt.base, tt.patterns...)
+			checkMatches(t, m, tt.cases)
+		})
+	}
+}
+
+func TestAddRelativeOverridesEarlierPatterns(t *testing.T) {
+	m := New([]string{"*.js"})
+	m.AddRelative("vendor", "!*.js")
+	checkMatches(t, m, []matchCase{
+		{"a.js", false, true},
+		{"vendor/a.js", false, false},
+	})
+}
+
+func TestClone(t *testing.T) {
+	original := New([]string{"*.log"})
+	clone := original.Clone()
+	clone.Add("*.tmp", "!keep.log")
+
+	checkMatches(t, original, []matchCase{
+		{"a.log", false, true},
+		{"keep.log", false, true},
+		{"a.tmp", false, false},
+	})
+	checkMatches(t, clone, []matchCase{
+		{"a.log", false, true},
+		{"keep.log", false, false},
+		{"a.tmp", false, true},
+	})
+
+	var nilMatcher *Matcher
+	checkMatches(t, nilMatcher.Clone(), []matchCase{{"a.log", false, false}})
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func() *Matcher
+		cases []matchCase
+	}{
+		{
+			name: "patterns are moved under the base",
+			setup: func() *Matcher {
+				m := &Matcher{}
+				m.Merge("scripts", New([]string{"/dist", "*.bak"}))
+				return m
+			},
+			cases: []matchCase{
+				{"scripts/dist", true, true},
+				{"dist", true, false},
+				{"scripts/x/a.bak", false, true},
+				{"a.bak", false, false},
+			},
+		},
+		{
+			name: "relative patterns keep their own base",
+			setup: func() *Matcher {
+				other := &Matcher{}
+				other.AddRelative("sub", "/out")
+				m := &Matcher{}
+				m.Merge("scripts", other)
+				return m
+			},
+			cases: []matchCase{
+				{"scripts/sub/out", true, true},
+				{"scripts/out", true, false},
+			},
+		},
+		{
+			name: "merged patterns take priority",
+			setup: func() *Matcher {
+				m := New([]string{"node_modules/"})
+				m.Merge("", New([]string{"!node_modules/"}))
+				return m
+			},
+			cases: []matchCase{
+				{"node_modules", true, false},
+			},
+		},
+		{
+			name: "nil matcher adds nothing",
+			setup: func() *Matcher {
+				m := New([]string{"*.log"})
+				m.Merge("sub", nil)
+				return m
+			},
+			cases: []matchCase{
+				{"a.log", false, true},
+				{"a.js", false, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkMatches(t, tt.setup(), tt.cases)
+		})
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *Matcher
+	if m.Match("anything", false) {
+		t.Error("nil matcher should not ignore anything")
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob string
+		want string
+	}{
+		{"a.js", `a\.js`},
+		{"*.js", `[^/]*\.js`},
+		{"?", `[^/]`},
+		{"**/a", `(.*/)?a`},
+		{"a/**", `a/.*`},
+		{"a/**/b", `a/(.*/)?b`},
+	}
+	for _, tt := range tests {
+		if got := GlobToRegexp(tt.glob); got != tt.want {
+			t.Errorf("GlobToRegexp(%q) = %q, want %q", tt.glob, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package placeholder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bwebb-hx/hxutil/internal/config"
+	hexaclient "github.com/bwebb-hx/hxutil/internal/hexaClient"
+)
+
+// placeholders look like ":p-id" or ":my_var". a colon followed by anything else (such as "https://" or a port) is left alone.
+// a double colon escapes a colon that isn't a placeholder, such as "sort=name::asc" for "sort=name:asc".
+var placeholderPattern = regexp.MustCompile(`::?([A-Za-z][\w-]*)`)
+
+// built in placeholders, and their aliases
+var (
+	projectIDNames   = []string{"p-id", "project-id"}
+	workspaceIDNames = []string{"w-id", "workspace-id"}
+	datastoreIDNames = []string{"d-id", "datastore-id"}
+	actionIDNames    = []string{"a-id", "action-id"}
+)
+
+// Resolver replaces placeholders such as ":p-id" with values from the selected project, and user-defined variables.
+//
+// Datastore and action IDs are looked up (by display ID) using the Hexabase API, so Client must be logged in to resolve them.
+type Resolver struct {
+	Client    *hexaclient.Client // used to look up datastores and actions
+	Datastore string             // display ID (or ID) of the datastore used for :d-id
+	Action    string             // display ID (or ID) of the action used for :a-id
+	Vars      map[string]string  // user-defined variables. these take priority over built in placeholders.
+
+	// LoadProject gives the project used for :p-id and :w-id, and to look up datastores. A nil project means none is selected.
+	// It's only called once a placeholder needs the project, so calls without one aren't held up by it. may be nil.
+	LoadProject func() (*config.Project, error)
+
+	RegisteredProjects []string // display IDs of projects that can be selected, shown when no project is selected
+
+	// cached lookups
+	project    *config.Project
+	projectErr error
+	loaded     bool
+	datastores *hexaclient.GetDatastoresResponse
+	actions    *hexaclient.GetActionsResponse
+}
+
+// Resolve replaces all placeholders in s, and unescapes double colons.
+// An error is returned for the first placeholder that can't be resolved, including names that aren't built in placeholders or variables.
+func (r *Resolver) Resolve(ctx context.Context, s string) (string, error) {
+	var resolveErr error
+	resolved := placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if resolveErr != nil {
+			return match
+		}
+		if strings.HasPrefix(match, "::") {
+			return match[1:]
+		}
+		value, err := r.lookup(ctx, match[1:])
+		if err != nil {
+			resolveErr = err
+			return match
+		}
+		return value
+	})
+	return resolved, resolveErr
+}
+
+// Project gives the selected project, loading it the first time it's needed
+func (r *Resolver) Project() (*config.Project, error) {
+	if !r.loaded && r.LoadProject != nil {
+		r.project, r.projectErr = r.LoadProject()
+	}
+	r.loaded = true
+	return r.project, r.projectErr
+}
+
+// requireProject gives the selected project, or an error naming the placeholder if there isn't one
+func (r *Resolver) requireProject(name string) (*config.Project, error) {
+	project, err := r.Project()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve :%s: %w", name, err)
+	}
+	if project == nil {
+		return nil, r.noProjectErr(name)
+	}
+	return project, nil
+}
+
+// Available lists all placeholders that can be used, such as ":p-id"
+func (r *Resolver) Available() []string {
+	available := make([]string, 0)
+	for _, names := range [][]string{projectIDNames, workspaceIDNames, datastoreIDNames, actionIDNames} {
+		for _, name := range names {
+			available = append(available, ":"+name)
+		}
+	}
+	varNames := make([]string, 0, len(r.Vars))
+	for name := range r.Vars {
+		varNames = append(varNames, ":"+name)
+	}
+	sort.Strings(varNames)
+	return append(available, varNames...)
+}
+
+// lookup gives the value of a placeholder
+func (r *Resolver) lookup(ctx context.Context, name string) (string, error) {
+	if value, exists := r.Vars[name]; exists {
+		return value, nil
+	}
+
+	switch {
+	case contains(projectIDNames, name):
+		project, err := r.requireProject(name)
+		if err != nil {
+			return "", err
+		}
+		return project.P_ID, nil
+	case contains(workspaceIDNames, name):
+		project, err := r.requireProject(name)
+		if err != nil {
+			return "", err
+		}
+		if project.WorkspaceID == "" {
+			return "", fmt.Errorf(":%s is unknown for project %s; register the project in config first", name, project.P_ID)
+		}
+		return project.WorkspaceID, nil
+	case contains(datastoreIDNames, name):
+		return r.datastoreID(ctx)
+	case contains(actionIDNames, name):
+		return r.actionID(ctx)
+	}
+	return "", fmt.Errorf("unknown placeholder :%s (available: %s); use :: for a literal colon", name, strings.Join(r.Available(), ", "))
+}
+
+func (r *Resolver) datastoreID(ctx context.Context) (string, error) {
+	project, err := r.requireProject("d-id")
+	if err != nil {
+		return "", err
+	}
+	if r.datastores == nil {
+		resp, err := r.Client.GetApi(ctx, fmt.Sprintf(hexaclient.GetDatastoresAPI.URI, project.P_ID), nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to get datastores: %w", err)
+		}
+		var datastores hexaclient.GetDatastoresResponse
+		if err := json.Unmarshal(resp, &datastores); err != nil {
+			return "", fmt.Errorf("failed to get datastores (are you logged in?): %s", strings.TrimSpace(string(resp)))
+		}
+		r.datastores = &datastores
+	}
+
+	displayIDs := make([]string, 0, len(*r.datastores))
+	for _, datastore := range *r.datastores {
+		if r.Datastore != "" && (datastore.DisplayID == r.Datastore || datastore.DatastoreID == r.Datastore) {
+			return datastore.DatastoreID, nil
+		}
+		displayIDs = append(displayIDs, datastore.DisplayID)
+	}
+	if r.Datastore == "" {
+		return "", fmt.Errorf(":d-id requires a datastore; select one with --datastore (available: %s)", listOrNone(displayIDs))
+	}
+	return "", fmt.Errorf("datastore %q not found in project %s (available: %s)", r.Datastore, project.P_ID, listOrNone(displayIDs))
+}
+
+func (r *Resolver) actionID(ctx context.Context) (string, error) {
+	if r.Datastore == "" {
+		return "", fmt.Errorf(":a-id requires a datastore; select one with --datastore")
+	}
+	d_id, err := r.datastoreID(ctx)
+	if err != nil {
+		return "", err
+	}
+	if r.actions == nil {
+		resp, err := r.Client.GetApi(ctx, fmt.Sprintf(hexaclient.GetActionsAPI.URI, d_id), nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to get actions: %w", err)
+		}
+		var actions hexaclient.GetActionsResponse
+		if err := json.Unmarshal(resp, &actions); err != nil {
+			return "", fmt.Errorf("failed to get actions: %s", strings.TrimSpace(string(resp)))
+		}
+		r.actions = &actions
+	}
+
+	displayIDs := make([]string, 0, len(*r.actions))
+	for _, action := range *r.actions {
+		if r.Action != "" && (action.DisplayID == r.Action || action.ActionID == r.Action) {
+			return action.ActionID, nil
+		}
+		displayIDs = append(displayIDs, action.DisplayID)
+	}
+	if r.Action == "" {
+		return "", fmt.Errorf(":a-id requires an action; select one with --action (available: %s)", listOrNone(displayIDs))
+	}
+	return "", fmt.Errorf("action %q not found in datastore %s (available: %s)", r.Action, r.Datastore, listOrNone(displayIDs))
+}
+
+func (r *Resolver) noProjectErr(name string) error {
+	return fmt.Errorf(":%s requires a project; select one with --project (registered: %s)", name, listOrNone(r.RegisteredProjects))
+}
+
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
+
+func listOrNone(list []string) string {
+	if len(list) == 0 {
+		return "none"
+	}
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,117 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/term"
+)
+
+// DEFAULT_CONCURRENCY is the number of workers used when no concurrency is set
+const DEFAULT_CONCURRENCY = 8
+
+var concurrency = DEFAULT_CONCURRENCY
+
+// SetConcurrency sets the number of workers used by Map. Values below 1 reset it to the default.
+func SetConcurrency(n int) {
+	if n < 1 {
+		n = DEFAULT_CONCURRENCY
+	}
+	concurrency = n
+}
+
+// Concurrency gives the number of workers used by Map
+func Concurrency() int {
+	return concurrency
+}
+
+// Result is the outcome of a single item
+type Result[R any] struct {
+	Value R
+	Err   error
+}
+
+// Map calls fn for every item, with a bounded number of workers, and gives the results in the same order as the items.
+//
+// Once ctx is cancelled, items that haven't started yet are skipped, and their error is the context's error.
+// If label is set, progress is shown on stderr while the items are worked on (only when stderr is a terminal).
+func Map[T, R any](ctx context.Context, label string, items []T, fn func(ctx context.Context, item T) (R, error)) []Result[R] {
+	results := make([]Result[R], len(items))
+	if len(items) == 0 {
+		return results
+	}
+
+	workers := min(concurrency, len(items))
+	progress := newProgress(label, len(items))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+				} else {
+					results[i].Value, results[i].Err = fn(ctx, items[i])
+				}
+				progress.increment()
+			}
+		}()
+	}
+	for i := range items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	progress.done()
+
+	return results
+}
+
+// progress shows how many items have been finished, on a single line that is rewritten as it changes
+type progress struct {
+	label    string
+	total    int
+	finished atomic.Int64
+	mu       sync.Mutex
+	enabled  bool
+}
+
+func newProgress(label string, total int) *progress {
+	p := &progress{
+		label:   label,
+		total:   total,
+		enabled: label != "" && term.IsTerminal(int(os.Stderr.Fd())),
+	}
+	p.print()
+	return p
+}
+
+func (p *progress) increment() {
+	p.finished.Add(1)
+	p.print()
+}
+
+func (p *progress) print() {
+	if !p.enabled {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "\r\033[K%s... %v/%v", p.label, p.finished.Load(), p.total)
+}
+
+// done clears the progress line
+func (p *progress) done() {
+	if !p.enabled {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
@@ -1,192 +1,532 @@
 package project
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
 
 	"github.com/bwebb-hx/hxutil/internal/action"
 	"github.com/bwebb-hx/hxutil/internal/config"
 	hx "github.com/bwebb-hx/hxutil/internal/hexaClient"
+	"github.com/bwebb-hx/hxutil/internal/report"
 	"github.com/bwebb-hx/hxutil/internal/utils"
 )
 
-func Diff(p1, p2 string) {
-	c := config.GetConfig()
-	if c == nil {
-		hx.PromptLogin()
-	} else {
-		c.SelectUserAndLogin(p1)
+// Side is one of the two projects being compared, along with the client used to access it.
+// Each side can have its own client, so the projects can be accessed as different users, or even in different environments.
+type Side struct {
+	Name   string // "p1" or "p2"
+	P_ID   string
+	Env    string // the environment the project is in
+	User   string // the user logged in as. empty if it isn't known.
+	Client *hx.Client
+}
+
+// String describes the project of the side, and where and how it's accessed
+func (s Side) String() string {
+	details := "env " + s.Env
+	if s.User != "" {
+		details += ", user " + s.User
 	}
+	return fmt.Sprintf("%s (%s)", s.P_ID, details)
+}
+
+// SideOptions choose one side of a diff. Fields that are left empty are prompted for, or taken from config.
+type SideOptions struct {
+	Project string // project ID or display ID
+	Env     string // environment the project is in. defaults to the selected environment.
+	User    string // email of a user registered in the environment to login as. defaults to the last user of the project.
+}
 
-	// if no projects provided, use config and prompt user
-	if p1 == "" {
-		utils.Hint("Select PID 1")
-		project1 := c.SelectProject()
-		if project1 == nil {
-			utils.Fatal("failed to select project", "project ID required for this utility")
+// SelectSides determines the two projects to compare, and logs in for each of them. Each side is resolved in its own environment.
+// p2 shares the login of p1 when they are in the same environment, unless p2 needs a different user.
+//
+// Both projects are checked to be accessible before anything is compared; an error naming the side is given if one isn't.
+func SelectSides(ctx context.Context, out io.Writer, opts1, opts2 SideOptions) (Side, Side, error) {
+	// catch mistyped environments before logging in to anything
+	if opts2.Env != "" {
+		if _, err := config.GetEnvironmentConfig(opts2.Env); err != nil {
+			return Side{}, Side{}, fmt.Errorf("p2: %w", err)
 		}
-		p1 = project1.P_ID
 	}
-	if p2 == "" {
-		utils.Hint("Select PID 2")
-		project2 := c.SelectProject()
-		if project2 == nil {
-			utils.Fatal("failed to select project", "project ID required for this utility")
+
+	p1, err := selectSide(ctx, out, "p1", opts1, nil)
+	if err != nil {
+		return Side{}, Side{}, err
+	}
+	p2, err := selectSide(ctx, out, "p2", opts2, &p1)
+	if err != nil {
+		return Side{}, Side{}, err
+	}
+
+	for _, side := range []Side{p1, p2} {
+		if err := checkAccess(ctx, side); err != nil {
+			return Side{}, Side{}, err
 		}
-		p2 = project2.P_ID
 	}
+	utils.HintTo(out, "p1: "+p1.String())
+	utils.HintTo(out, "p2: "+p2.String())
+	return p1, p2, nil
+}
 
-	// diff project settings and env variables
-	diffProjectSettings(p1, p2)
-	utils.EnterToContinue()
+// selectSide determines the project of one side, and logs in for it. other is the side that has already been selected, if any.
+func selectSide(ctx context.Context, out io.Writer, name string, opts SideOptions, other *Side) (Side, error) {
+	env := opts.Env
+	if env == "" {
+		env = config.SelectedEnvironment()
+	}
+	side := Side{Name: name, P_ID: opts.Project, Env: env}
 
-	// diff functions
-	diffFunctionActionScripts(p1, p2)
-	utils.EnterToContinue()
+	// the config is loaded for each side, so that one side sees what the other saved
+	c, err := config.GetEnvironmentConfig(env)
+	if err != nil {
+		if opts.Env != "" {
+			return side, fmt.Errorf("%s: %w", name, err)
+		}
+		utils.WarnTo(out, "failed to load config", err.Error())
+		if side.P_ID == "" {
+			return side, fmt.Errorf("%s: a project ID is required when config can't be loaded", name)
+		}
+		if other != nil {
+			// p1's login is only good for p2 if they are in the same environment
+			if other.Env != env {
+				return side, fmt.Errorf("%s: can't login to the %s environment without config (p1 is in %s)", name, env, other.Env)
+			}
+			side.Client = other.Client
+			return side, nil
+		}
+		side.Client = hx.NewClient("", "")
+		if _, err := side.Client.PromptLogin(ctx); err != nil {
+			return side, fmt.Errorf("%s: %w", name, err)
+		}
+		return side, nil
+	}
 
-	// diff actionscripts
-	diffDatastoreActionScripts(p1, p2)
-	utils.EnterToContinue()
-}
+	// if no project provided, use config and prompt user
+	client := c.NewClient()
+	if side.P_ID == "" {
+		utils.HintTo(out, "Select project for "+name)
+		project, err := c.SelectProject(ctx, client)
+		if err != nil {
+			return side, fmt.Errorf("%s: failed to select project: %w", name, err)
+		}
+		side.P_ID = project.P_ID
+	} else if project := c.GetProject(side.P_ID); project != nil {
+		// display IDs can be given for registered projects, but APIs need the project ID
+		side.P_ID = project.P_ID
+	}
 
-func diffProjectSettings(p1, p2 string) {
-	utils.Hint("Diffing Project Settings...")
+	side.User = opts.User
+	if side.User == "" {
+		side.User = lastLoginUser(c, side.P_ID)
+	}
+	if other != nil && other.Env == env && (side.User == "" || side.User == other.User) {
+		side.Client = other.Client
+		side.User = other.User
+		return side, nil
+	}
 
-	p1Bytes, err := hx.GetApi(hx.UN_GetProjectSettingsAPI.URI, map[string]string{"p_id": p1})
+	switch {
+	case opts.User != "":
+		user := c.GetUser(opts.User)
+		if user == nil {
+			return side, fmt.Errorf("%s: user not registered in the %s environment: %s", name, env, opts.User)
+		}
+		if err := user.Login(ctx, client); err != nil {
+			return side, fmt.Errorf("%s: %w", name, err)
+		}
+	case client.Token != "":
+		// already logged in to register the project
+	default:
+		utils.HintTo(out, fmt.Sprintf("Login for %s (%s)", name, side.P_ID))
+		if err := c.SelectUserAndLogin(ctx, client, side.P_ID); err != nil {
+			return side, fmt.Errorf("%s: %w", name, err)
+		}
+		side.User = lastLoginUser(c, side.P_ID)
+	}
+	side.Client = client
+	return side, nil
+}
+
+// checkAccess confirms that the project of a side can be accessed, so an inaccessible project isn't mistaken for differences
+func checkAccess(ctx context.Context, side Side) error {
+	settingsBytes, err := side.Client.GetApi(ctx, hx.UN_GetProjectSettingsAPI.URI, map[string]string{"p_id": side.P_ID})
 	if err != nil {
-		utils.Fatal("failed to get project", err.Error())
+		return fmt.Errorf("%s is not accessible: %s: %w", side.Name, side, describeApiError(err, side))
+	}
+	var settings hx.UN_GetProjectSettingsResponse
+	if err := json.Unmarshal(settingsBytes, &settings); err != nil || settings.PID == "" {
+		return fmt.Errorf("%s is not accessible: %s: project not found, or not accessible", side.Name, side)
+	}
+	return nil
+}
+
+// lastLoginUser gives the user that a registered project was last used with, if any
+func lastLoginUser(c *config.Config, p_id string) string {
+	if project := c.GetProject(p_id); project != nil {
+		return project.LastLoginUser
 	}
-	var p1SettingsResponse hx.UN_GetProjectSettingsResponse
-	if err = json.Unmarshal(p1Bytes, &p1SettingsResponse); err != nil {
-		utils.Fatal("failed to unmarshal json", err.Error())
+	return ""
+}
+
+// sections of a project diff
+const (
+	SECTION_SETTINGS  = "settings"  // names and display ID
+	SECTION_ENV       = "env"       // environment (script) variables
+	SECTION_FUNCTIONS = "functions" // function actionscripts
+	SECTION_ACTIONS   = "actions"   // datastore actionscripts
+)
+
+// Sections are all the sections of a project diff, in the order they are diffed
+var Sections = []string{SECTION_SETTINGS, SECTION_ENV, SECTION_FUNCTIONS, SECTION_ACTIONS}
+
+// ValidSection reports whether name is one of Sections
+func ValidSection(name string) bool {
+	return slices.Contains(Sections, name)
+}
+
+// DiffOptions control what a project diff compares, and how it's shown
+type DiffOptions struct {
+	Sections []string // sections to diff (see Sections). all of them are diffed if empty.
+	NoPause  bool     // don't wait for Enter after each section and each difference, so the diff can run unattended
+}
+
+func (opts DiffOptions) includes(section string) bool {
+	return len(opts.Sections) == 0 || slices.Contains(opts.Sections, section)
+}
+
+// pause waits for Enter, unless pausing is turned off
+func (opts DiffOptions) pause() {
+	if !opts.NoPause {
+		utils.EnterToContinue()
+	}
+}
+
+// sectionResults are the results that a section added to the report
+type sectionResults struct {
+	name    string
+	results []report.Result
+}
+
+// Diff compares the settings, env variables, functions and actionscripts of two projects, or just the sections chosen in opts.
+// The returned report has a result for everything that was compared, and a summary of all the sections is printed at the end.
+//
+// Actionscripts are downloaded concurrently. If ctx is cancelled, the actionscripts that haven't been downloaded are reported as errors.
+// If a section fails, it's recorded as an error in the report and the rest of the diff still goes ahead; the errors are
+// given along with the report.
+// Once ctx is cancelled, the sections that haven't started yet are skipped.
+func Diff(ctx context.Context, out io.Writer, p1, p2 Side, opts DiffOptions) (*report.Report, error) {
+	title := fmt.Sprintf("%s vs %s", p1.P_ID, p2.P_ID)
+	if p1.Env != p2.Env {
+		title = fmt.Sprintf("%s (%s) vs %s (%s)", p1.P_ID, p1.Env, p2.P_ID, p2.Env)
+	}
+	rep := report.New("project diff", title)
+
+	// settings and env variables come from the same API, so they are only fetched once
+	var settings1, settings2 *hx.UN_GetProjectSettingsResponse
+	loadSettings := func(ctx context.Context) error {
+		if settings1 != nil && settings2 != nil {
+			return nil
+		}
+		var err error
+		if settings1, err = getProjectSettings(ctx, p1); err != nil {
+			return err
+		}
+		settings2, err = getProjectSettings(ctx, p2)
+		return err
+	}
+
+	sections := []struct {
+		name string
+		diff func(ctx context.Context) error
+	}{
+		{SECTION_SETTINGS, func(ctx context.Context) error {
+			if err := loadSettings(ctx); err != nil {
+				return err
+			}
+			diffProjectSettings(out, *settings1, *settings2, rep)
+			return nil
+		}},
+		{SECTION_ENV, func(ctx context.Context) error {
+			if err := loadSettings(ctx); err != nil {
+				return err
+			}
+			diffEnvVars(out, *settings1, *settings2, rep)
+			return nil
+		}},
+		{SECTION_FUNCTIONS, func(ctx context.Context) error {
+			return diffFunctionActionScripts(ctx, out, p1, p2, opts, rep)
+		}},
+		{SECTION_ACTIONS, func(ctx context.Context) error {
+			return diffDatastoreActionScripts(ctx, out, p1, p2, opts, rep)
+		}},
 	}
 
-	p2Bytes, err := hx.GetApi(hx.UN_GetProjectSettingsAPI.URI, map[string]string{"p_id": p2})
+	errs := make([]error, 0)
+	diffed := make([]sectionResults, 0, len(sections))
+	for _, section := range sections {
+		if !opts.includes(section.name) || ctx.Err() != nil {
+			continue
+		}
+		start := len(rep.Results)
+		if err := section.diff(ctx); err != nil && ctx.Err() == nil {
+			utils.ErrorTo(out, "failed to diff "+section.name, err.Error())
+			rep.Add(report.Result{DisplayID: section.name, ScriptType: "section", Status: report.StatusError, Message: err.Error()})
+			errs = append(errs, fmt.Errorf("%s: %w", section.name, err))
+		}
+		diffed = append(diffed, sectionResults{name: section.name, results: rep.Results[start:]})
+		if ctx.Err() == nil {
+			opts.pause()
+		}
+	}
+
+	printSummary(out, p1, p2, diffed)
+	return rep, errors.Join(errs...)
+}
+
+// printSummary prints the counts of each section that was diffed, followed by everything that didn't match
+func printSummary(out io.Writer, p1, p2 Side, sections []sectionResults) {
+	statuses := []report.Status{report.StatusMatch, report.StatusDiff, report.StatusMissingP1, report.StatusMissingP2, report.StatusError, report.StatusInfo}
+
+	fmt.Fprintln(out, "\nSUMMARY\n=======")
+	fmt.Fprintln(out, "p1:", p1)
+	fmt.Fprintln(out, "p2:", p2)
+	fmt.Fprintln(out)
+
+	row := func(name string, counts map[report.Status]int) {
+		fmt.Fprintf(out, "%-10s", name)
+		for _, status := range statuses {
+			fmt.Fprintf(out, " %10d", counts[status])
+		}
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintf(out, "%-10s", "SECTION")
+	for _, status := range statuses {
+		fmt.Fprintf(out, " %10s", strings.ToUpper(string(status)))
+	}
+	fmt.Fprintln(out)
+	total := make(map[report.Status]int)
+	for _, section := range sections {
+		counts := make(map[report.Status]int)
+		for _, result := range section.results {
+			counts[result.Status]++
+			total[result.Status]++
+		}
+		row(section.name, counts)
+	}
+	row("total", total)
+
+	drift := 0
+	for _, section := range sections {
+		for _, result := range section.results {
+			if !result.Status.IsDrift() {
+				continue
+			}
+			if drift == 0 {
+				fmt.Fprintln(out)
+			}
+			drift++
+			c := utils.ColorError
+			if result.Status == report.StatusDiff {
+				c = utils.ColorWarn
+			}
+			c.Fprintf(out, "%s: %s\n", strings.ToUpper(string(result.Status)), result.Name())
+			if result.Status == report.StatusError && result.Message != "" {
+				utils.ColorHint.Fprintln(out, "  "+result.Message)
+			}
+		}
+	}
+	if drift == 0 {
+		utils.ColorSuccess.Fprintln(out, "\nNo differences found")
+	} else if total[report.StatusMissingP1] > 0 || total[report.StatusMissingP2] > 0 {
+		utils.HintTo(out, "(confirm that display IDs match between projects for anything missing)")
+	}
+	fmt.Fprintln(out, "=======\n ")
+}
+
+// getProjectSettings gets the settings of the project of a side
+func getProjectSettings(ctx context.Context, side Side) (*hx.UN_GetProjectSettingsResponse, error) {
+	settingsBytes, err := side.Client.GetApi(ctx, hx.UN_GetProjectSettingsAPI.URI, map[string]string{"p_id": side.P_ID})
 	if err != nil {
-		utils.Fatal("failed to get project", err.Error())
+		return nil, fmt.Errorf("failed to get project: %w", describeApiError(err, side))
 	}
-	var p2SettingsResponse hx.UN_GetProjectSettingsResponse
-	if err = json.Unmarshal(p2Bytes, &p2SettingsResponse); err != nil {
-		utils.Fatal("failed to unmarshal json", err.Error())
+	var settings hx.UN_GetProjectSettingsResponse
+	if err = json.Unmarshal(settingsBytes, &settings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal project settings: %w", err)
 	}
+	return &settings, nil
+}
+
+func diffProjectSettings(out io.Writer, p1Settings, p2Settings hx.UN_GetProjectSettingsResponse, rep *report.Report) {
+	utils.HintTo(out, "Diffing Project Settings...")
+	utils.HintTo(out, fmt.Sprintf("p1: %s [%s]", p1Settings.DisplayID, p1Settings.PID))
+	utils.HintTo(out, fmt.Sprintf("p2: %s [%s]", p2Settings.DisplayID, p2Settings.PID))
 
-	utils.Hint(fmt.Sprintf("p1: %s [%s]", p1SettingsResponse.DisplayID, p1SettingsResponse.PID))
-	utils.Hint(fmt.Sprintf("p2: %s [%s]", p2SettingsResponse.DisplayID, p2SettingsResponse.PID))
+	// compare high level details (names, etc). these tell the projects apart, so they are expected to differ
+	identity := []report.Result{
+		diffValues(out, p1Settings.Name.En, p2Settings.Name.En, "Name (En)", "setting"),
+		diffValues(out, p1Settings.Name.Ja, p2Settings.Name.Ja, "Name (Ja)", "setting"),
+		diffValues(out, p1Settings.DisplayID, p2Settings.DisplayID, "Display ID", "setting"),
+	}
+	for _, result := range identity {
+		if result.Status == report.StatusDiff {
+			result.Status = report.StatusInfo
+		}
+		rep.Add(result)
+	}
+}
 
-	// compare high level details (names, etc)
-	diffValues(p1SettingsResponse.Name.En, p2SettingsResponse.Name.En, "Name (En)")
-	diffValues(p1SettingsResponse.Name.Ja, p2SettingsResponse.Name.Ja, "Name (Ja)")
-	diffValues(p1SettingsResponse.DisplayID, p2SettingsResponse.DisplayID, "Display ID")
+func diffEnvVars(out io.Writer, p1Settings, p2Settings hx.UN_GetProjectSettingsResponse, rep *report.Report) {
+	utils.HintTo(out, "Diffing Environment Variables...")
 
-	// compare env vars
-	for _, envVar := range p1SettingsResponse.ScriptVars {
+	for _, envVar := range p1Settings.ScriptVars {
 		found := false
-		for _, envVar2 := range p2SettingsResponse.ScriptVars {
+		for _, envVar2 := range p2Settings.ScriptVars {
 			if envVar.VarName == envVar2.VarName {
-				diffValues(envVar.Value, envVar2.Value, envVar.VarName+" (Env)")
+				rep.Add(diffValues(out, envVar.Value, envVar2.Value, envVar.VarName, "env"))
 				found = true
 				break
 			}
 		}
 		if !found {
-			utils.Warn("environment variable match not found: "+envVar.VarName, "(exists in p1 but not p2)")
+			utils.WarnTo(out, "environment variable match not found: "+envVar.VarName, "(exists in p1 but not p2)")
+			rep.Add(report.Result{DisplayID: envVar.VarName, ScriptType: "env", Status: report.StatusMissingP2})
 		}
 	}
 	// confirm that there aren't extra env vars in p2
-	for _, envVar := range p2SettingsResponse.ScriptVars {
+	for _, envVar := range p2Settings.ScriptVars {
 		found := false
-		for _, envVar2 := range p1SettingsResponse.ScriptVars {
+		for _, envVar2 := range p1Settings.ScriptVars {
 			if envVar.VarName == envVar2.VarName {
 				found = true
 				break
 			}
 		}
 		if !found {
-			utils.Warn("environment variable match not found: "+envVar.VarName, "(exists in p2 but not p1)")
+			utils.WarnTo(out, "environment variable match not found: "+envVar.VarName, "(exists in p2 but not p1)")
+			rep.Add(report.Result{DisplayID: envVar.VarName, ScriptType: "env", Status: report.StatusMissingP1})
+		}
+	}
+}
+
+// describeApiError explains an error from calling an API for the project of a side
+func describeApiError(err error, side Side) error {
+	if hx.IsNotFound(err) {
+		return fmt.Errorf("project not found in the %s environment: %s", side.Env, side.P_ID)
+	}
+	if hx.IsUnauthorized(err) {
+		if side.User != "" {
+			return fmt.Errorf("project not accessible by %s: %s", side.User, side.P_ID)
 		}
+		return fmt.Errorf("project not accessible by the logged in user: %s", side.P_ID)
 	}
+	return err
 }
 
-func diffValues(p1Val, p2Val string, valueName string) {
+// diffValues shows the difference between two values, if any. valueType is the script type to use in the report.
+func diffValues(out io.Writer, p1Val, p2Val string, valueName string, valueType string) report.Result {
+	result := report.Result{
+		DisplayID:  valueName,
+		ScriptType: valueType,
+		Status:     report.StatusMatch,
+	}
 	if p1Val != p2Val {
-		fmt.Println("\nDiff found!:", valueName)
+		fmt.Fprintln(out, "\nDiff found!:", valueName, fmt.Sprintf("(%s)", valueType))
+		result.Status = report.StatusDiff
+		result.Diff = utils.GetUnifiedDiff(p1Val, p2Val, "p1/"+valueName, "p2/"+valueName)
 
 		if len(p1Val) > 50 && len(p2Val) > 50 {
-			utils.Hint("(Showing diff since values are large)")
-			fmt.Println(utils.GetDiff(p1Val, p2Val))
-			return
+			utils.HintTo(out, "(Showing diff since values are large)")
+			fmt.Fprintln(out, utils.GetDiff(p1Val, p2Val))
+			return result
 		}
 
-		fmt.Println("p1:", p1Val)
-		fmt.Println("p2:", p2Val)
+		fmt.Fprintln(out, "p1:", p1Val)
+		fmt.Fprintln(out, "p2:", p2Val)
 	}
+	return result
 }
 
-func diffFunctionActionScripts(p1, p2 string) {
-	utils.Hint("Diffing Project Functions...")
+func diffFunctionActionScripts(ctx context.Context, out io.Writer, p1, p2 Side, opts DiffOptions, rep *report.Report) error {
+	utils.HintTo(out, "Diffing Project Functions...")
 	// get action scripts for functions
-	p1FnBytes, err := hx.GetApi(hx.UN_GetFunctionActionScriptAPI.URI, map[string]string{
-		"p_id": p1,
+	p1FnBytes, err := p1.Client.GetApi(ctx, hx.UN_GetFunctionActionScriptAPI.URI, map[string]string{
+		"p_id": p1.P_ID,
 	})
 	if err != nil {
-		utils.Fatal("failed to get functions", err.Error())
+		return fmt.Errorf("failed to get functions: %w", describeApiError(err, p1))
 	}
 	var p1Functions hx.UN_GetFunctionActionScriptResponse
 	if err = json.Unmarshal(p1FnBytes, &p1Functions); err != nil {
-		utils.Fatal("failed to unmarshal", err.Error())
+		return fmt.Errorf("failed to unmarshal functions: %w", err)
 	}
 
-	p2FnBytes, err := hx.GetApi(hx.UN_GetFunctionActionScriptAPI.URI, map[string]string{
-		"p_id": p2,
+	p2FnBytes, err := p2.Client.GetApi(ctx, hx.UN_GetFunctionActionScriptAPI.URI, map[string]string{
+		"p_id": p2.P_ID,
 	})
 	if err != nil {
-		utils.Fatal("failed to get functions", err.Error())
+		return fmt.Errorf("failed to get functions: %w", describeApiError(err, p2))
 	}
 	var p2Functions hx.UN_GetFunctionActionScriptResponse
 	if err = json.Unmarshal(p2FnBytes, &p2Functions); err != nil {
-		utils.Fatal("failed to unmarshal", err.Error())
+		return fmt.Errorf("failed to unmarshal functions: %w", err)
 	}
 
 	// diff function actionscripts
-	diffLogs := []string{}
 	for _, function := range p1Functions {
 		found := false
 		for _, function2 := range p2Functions {
 			if function.DisplayID == function2.DisplayID {
 				// match found; diff contents
 				found = true
+				result := report.Result{
+					ActionID:   function.FunctionID,
+					DisplayID:  function.DisplayID,
+					ScriptType: "function",
+				}
 
 				if function.Pre.Script == "" || function2.Pre.Script == "" {
 					if function.Pre.Script != "" {
-						utils.ColorError.Println("!!MISSING: Function script defined in p1 but not p2")
-						diffLogs = append(diffLogs, fmt.Sprintf("%s %s (Function)", utils.ColorError.Sprint("MISSING IN P2:"), function.DisplayID))
+						utils.ColorError.Fprintln(out, "!!MISSING: Function script defined in p1 but not p2")
+						result.Status = report.StatusMissingP2
+						rep.Add(result)
 						break
 					}
 					if function2.Pre.Script != "" {
-						utils.ColorError.Println("!!MISSING: Function script defined in p2 but not p1")
-						diffLogs = append(diffLogs, fmt.Sprintf("%s %s (Function)", utils.ColorError.Sprint("MISSING IN P1:"), function.DisplayID))
+						utils.ColorError.Fprintln(out, "!!MISSING: Function script defined in p2 but not p1")
+						result.Status = report.StatusMissingP1
+						rep.Add(result)
 						break
 					}
 					// both are empty?
-					utils.ColorError.Printf("Empty? Function %s in both projects is empty...\n", function.DisplayID)
-					diffLogs = append(diffLogs, utils.ColorError.Sprintf("Empty Functions: %s in both projects is empty...", function.DisplayID))
+					utils.ColorError.Fprintf(out, "Empty? Function %s in both projects is empty...\n", function.DisplayID)
+					result.Status = report.StatusMatch
+					result.Message = "empty in both projects"
+					rep.Add(result)
 					break
 				}
 
+				result.Status = report.StatusMatch
 				diff := utils.GetDiff(function.Pre.Script, function2.Pre.Script)
 				if diff != "" {
-					diffLogs = append(diffLogs, fmt.Sprintf("%s %s (Function)", utils.ColorWarn.Sprint("DIFF FOUND:"), function.DisplayID))
-					utils.ColorWarn.Println("\nDiff Found!", function.DisplayID, "(Function)")
-					fmt.Println(diff)
-					utils.Hint("(End Diff)")
-					utils.EnterToContinue()
+					utils.ColorWarn.Fprintln(out, "\nDiff Found!", function.DisplayID, "(Function)")
+					fmt.Fprintln(out, diff)
+					utils.HintTo(out, "(End Diff)")
+					opts.pause()
+
+					result.Status = report.StatusDiff
+					result.Diff = utils.GetUnifiedDiff(function.Pre.Script, function2.Pre.Script, "p1/"+function.DisplayID, "p2/"+function.DisplayID)
 				}
+				rep.Add(result)
 				break
 			}
 		}
 		if !found {
-			diffLogs = append(diffLogs, fmt.Sprintf("? NO MATCH: Function %s found in P1 but not P2.", function.DisplayID))
-			diffLogs = append(diffLogs, utils.ColorHint.Sprint("  Confirm function exists and display IDs match between projects"))
+			rep.Add(report.Result{ActionID: function.FunctionID, DisplayID: function.DisplayID, ScriptType: "function", Status: report.StatusMissingP2})
 		}
 	}
 	// make sure there aren't functions in p2 that aren't in p1
@@ -199,83 +539,122 @@ func diffFunctionActionScripts(p1, p2 string) {
 			}
 		}
 		if !found {
-			diffLogs = append(diffLogs, fmt.Sprintf("? NO MATCH: Function %s found in P2 but not P1.", function.DisplayID))
-			diffLogs = append(diffLogs, utils.ColorHint.Sprint("  Confirm function exists and display IDs match between projects"))
+			rep.Add(report.Result{ActionID: function.FunctionID, DisplayID: function.DisplayID, ScriptType: "function", Status: report.StatusMissingP1})
 		}
 	}
 
-	fmt.Println("\n== SUMMARY ==")
-	for _, fn := range diffLogs {
-		fmt.Println(fn)
-	}
+	return nil
+}
+
+// an action matches if it has the same display ID, and the same datastore name
+func actionsMatch(action1, action2 action.Action) bool {
+	return action1.DisplayID == action2.DisplayID && action1.DatastoreName == action2.DatastoreName
 }
 
-func diffDatastoreActionScripts(p1, p2 string) {
-	utils.Hint("Diffing Datastore ActionScripts...")
+func diffDatastoreActionScripts(ctx context.Context, out io.Writer, p1, p2 Side, opts DiffOptions, rep *report.Report) error {
+	utils.HintTo(out, "Diffing Datastore ActionScripts...")
 
-	p1Actions := action.GetProjectActions(p1)
-	p2Actions := action.GetProjectActions(p2)
+	p1Actions, err := action.GetProjectActions(ctx, out, p1.Client, p1.P_ID)
+	if err != nil {
+		return fmt.Errorf("p1: %w", describeApiError(err, p1))
+	}
+	p2Actions, err := action.GetProjectActions(ctx, out, p2.Client, p2.P_ID)
+	if err != nil {
+		return fmt.Errorf("p2: %w", describeApiError(err, p2))
+	}
 	if len(p1Actions) == 0 {
-		utils.Hint("(No actions found for p1)")
+		utils.HintTo(out, "(No actions found for p1)")
 	}
 	if len(p2Actions) == 0 {
-		utils.Hint("(No actions found for p2)")
+		utils.HintTo(out, "(No actions found for p2)")
 	}
 	if len(p1Actions) == 0 || len(p2Actions) == 0 {
-		return
+		return nil
+	}
+
+	// download the scripts of all matching actions up front, in the same order they are diffed below.
+	// each project's scripts are downloaded with its own client.
+	p1Refs := make([]action.ScriptRef, 0)
+	p2Refs := make([]action.ScriptRef, 0)
+	for _, action1 := range p1Actions {
+		for _, action2 := range p2Actions {
+			if actionsMatch(action1, action2) {
+				for _, scriptType := range []string{"pre", "post"} {
+					p1Refs = append(p1Refs, action.ScriptRef{Action: action1, ScriptType: scriptType})
+					p2Refs = append(p2Refs, action.ScriptRef{Action: action2, ScriptType: scriptType})
+				}
+			}
+		}
 	}
+	p1Downloads := action.DownloadActionScripts(ctx, p1.Client, p1Refs)
+	p2Downloads := action.DownloadActionScripts(ctx, p2.Client, p2Refs)
 
 	// find matching actions and diff them
-	// an action matches if it has the same display ID, and the same datastore name
-	diffLog := make([]string, 0)
+	next := 0
 	for _, action1 := range p1Actions {
 		found := false
 		for _, action2 := range p2Actions {
-			if action1.DisplayID == action2.DisplayID {
-				if action1.DatastoreName != action2.DatastoreName {
-					continue
-				}
+			if actionsMatch(action1, action2) {
 				found = true
 
 				diffScripts := func(scriptType string) {
-					// download actionscripts
-					script1, err := action.DownloadActionScript(action1.ID, scriptType)
+					download1, download2 := p1Downloads[next], p2Downloads[next]
+					next++
+
+					result := report.Result{
+						ActionID:   action1.ID,
+						DisplayID:  action1.DisplayID,
+						Datastore:  action1.DatastoreName,
+						ScriptType: scriptType,
+					}
+
+					script1, err := download1.Value, download1.Err
 					if err != nil {
-						utils.Error("error while downloading actionscript", err.Error())
+						utils.ErrorTo(out, "error while downloading actionscript", err.Error())
+						result.Status = report.StatusError
+						result.Message = "p1: " + err.Error()
+						rep.Add(result)
 						return
 					}
-					script2, err := action.DownloadActionScript(action2.ID, scriptType)
+					script2, err := download2.Value, download2.Err
 					if err != nil {
-						utils.Error("error while downloading actionscript", err.Error())
+						utils.ErrorTo(out, "error while downloading actionscript", err.Error())
+						result.Status = report.StatusError
+						result.Message = "p2: " + err.Error()
+						rep.Add(result)
 						return
 					}
 					if script1 == "" || script2 == "" {
 						// one is empty, but not the other
 						if script1 != "" || script2 != "" {
 							if script1 != "" {
-								utils.ColorError.Println("!!MISSING: ActionScript defined in p1 but not p2")
-								diffLog = append(diffLog, fmt.Sprintf("%s %s (%s) [%s]", utils.ColorError.Sprint("MISSING IN P2:"), action1.DisplayID, scriptType, action1.DatastoreName))
+								utils.ColorError.Fprintln(out, "!!MISSING: ActionScript defined in p1 but not p2")
+								result.Status = report.StatusMissingP2
 							} else {
-								utils.ColorError.Println("!!MISSING: ActionScript defined in p2 but not p1")
-								diffLog = append(diffLog, fmt.Sprintf("%s %s (%s) [%s]", utils.ColorError.Sprint("MISSING IN P1:"), action1.DisplayID, scriptType, action1.DatastoreName))
+								utils.ColorError.Fprintln(out, "!!MISSING: ActionScript defined in p2 but not p1")
+								result.Status = report.StatusMissingP1
 							}
-							utils.ColorWarn.Printf("Action: %s (%s)  Datastore: %s\n", action1.Name, scriptType, action1.DatastoreName)
+							utils.ColorWarn.Fprintf(out, "Action: %s (%s)  Datastore: %s\n", action1.Name, scriptType, action1.DatastoreName)
+							rep.Add(result)
 						}
 						return
 					}
 
 					// diff
+					result.Status = report.StatusMatch
 					diff := utils.GetDiff(script1, script2)
 					if diff != "" {
-						utils.ColorWarn.Println("\nDiff Found!")
-						utils.ColorWarn.Printf("Action: %s (%s)  Datastore: %s\n", action1.DisplayID, scriptType, action1.DatastoreName)
-						fmt.Println(diff)
-						utils.Hint("(End Diff)")
-
-						utils.EnterToContinue()
-
-						diffLog = append(diffLog, fmt.Sprintf("%s %s (%s) [%s]", utils.ColorWarn.Sprint("DIFF FOUND:"), action1.DisplayID, scriptType, action1.DatastoreName))
+						utils.ColorWarn.Fprintln(out, "\nDiff Found!")
+						utils.ColorWarn.Fprintf(out, "Action: %s (%s)  Datastore: %s\n", action1.DisplayID, scriptType, action1.DatastoreName)
+						fmt.Fprintln(out, diff)
+						utils.HintTo(out, "(End Diff)")
+						opts.pause()
+
+						result.Status = report.StatusDiff
+						fileName := fmt.Sprintf("%s.%s.js", action1.DisplayID, scriptType)
+						result.Diff = utils.GetUnifiedDiff(script1, script2, "p1/"+fileName, "p2/"+fileName)
 					}
+					rep.Add(result)
 				}
 
 				diffScripts("pre")
@@ -284,8 +663,7 @@ func diffDatastoreActionScripts(p1, p2 string) {
 		}
 
 		if !found {
-			diffLog = append(diffLog, fmt.Sprintf("? NO MATCH: Action %s [%s] found in P1 but not P2.", action1.DisplayID, action1.DatastoreName))
-			diffLog = append(diffLog, utils.ColorHint.Sprint("  Confirm action exists and display IDs (action and datastore) match between projects"))
+			rep.Add(report.Result{ActionID: action1.ID, DisplayID: action1.DisplayID, Datastore: action1.DatastoreName, ScriptType: "action", Status: report.StatusMissingP2})
 		}
 	}
 
@@ -293,20 +671,15 @@ func diffDatastoreActionScripts(p1, p2 string) {
 	for _, action2 := range p2Actions {
 		found := false
 		for _, action1 := range p1Actions {
-			if action2.DisplayID == action1.DisplayID && action2.DatastoreName == action1.DatastoreName {
+			if actionsMatch(action1, action2) {
 				found = true
 				break
 			}
 		}
 		if !found {
-			diffLog = append(diffLog, fmt.Sprintf("? NO MATCH: Action %s [%s] found in P2 but not P1.", action2.DisplayID, action2.DatastoreName))
-			diffLog = append(diffLog, utils.ColorHint.Sprint("  Confirm action exists and display IDs (action and datastore) match between projects"))
+			rep.Add(report.Result{ActionID: action2.ID, DisplayID: action2.DisplayID, Datastore: action2.DatastoreName, ScriptType: "action", Status: report.StatusMissingP1})
 		}
 	}
 
-	// print summary
-	fmt.Println("\n== SUMMARY ==")
-	for _, s := range diffLog {
-		fmt.Println(s)
-	}
+	return nil
 }
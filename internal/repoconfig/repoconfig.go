@@ -0,0 +1,114 @@
+package repoconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// name of the repo config file, which is looked for in the working directory and all of its parents
+const FILE_NAME = ".hxutil.yaml"
+
+// RepoConfig binds a repository to a Hexabase project, so commands run in it don't have to ask which project to use.
+type RepoConfig struct {
+	Project string `yaml:"project"`        // project ID or display ID
+	Env     string `yaml:"env,omitempty"`  // environment from the global config. empty means the default environment.
+	User    string `yaml:"user,omitempty"` // registered user to login with
+
+	ScriptsDir   string   `yaml:"scripts_dir,omitempty"`   // directory where scripts are kept, relative to the repo config file
+	FunctionsDir string   `yaml:"functions_dir,omitempty"` // directory where function scripts are kept, relative to scripts_dir
+	Ignore       []string `yaml:"ignore,omitempty"`        // paths (relative to scripts_dir) to skip when looking for scripts, in .gitignore style. added to the defaults (node_modules/, .git/)
+	Mapping      Mapping  `yaml:"mapping,omitempty"`
+
+	// directory that holds the repo config file
+	root string
+}
+
+// Mapping configures how scripts are located, relative to scripts_dir
+type Mapping struct {
+	Actions   string `yaml:"actions,omitempty"`   // template for action scripts, such as "{datastore}/{action}.{type}.{ext}"
+	Functions string `yaml:"functions,omitempty"` // template for function scripts, such as "functions/{function}.{ext}"
+	Manifest  string `yaml:"manifest,omitempty"`  // file that maps action and function IDs to paths, relative to the repo config file
+}
+
+// Find looks for the repo config file in dir and each of its parents. nil is returned if there isn't one.
+func Find(dir string) (*RepoConfig, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(absDir, FILE_NAME)
+		if _, err := os.Stat(path); err == nil {
+			return Load(path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		parent := filepath.Dir(absDir)
+		if parent == absDir {
+			// reached the filesystem root
+			return nil, nil
+		}
+		absDir = parent
+	}
+}
+
+// Load reads a repo config file.
+func Load(path string) (*RepoConfig, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rc RepoConfig
+	if err := yaml.Unmarshal(fileBytes, &rc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	rc.root = filepath.Dir(absPath)
+	return &rc, nil
+}
+
+// Save writes the repo config file into dir.
+func (rc *RepoConfig) Save(dir string) (string, error) {
+	rcBytes, err := yaml.Marshal(rc)
+	if err != nil {
+		return "", err
+	}
+	header := "# hxutil repo config. binds this repository to a Hexabase project.\n"
+	path := filepath.Join(dir, FILE_NAME)
+	if err := os.WriteFile(path, append([]byte(header), rcBytes...), 0644); err != nil {
+		return "", err
+	}
+	rc.root, err = filepath.Abs(dir)
+	return path, err
+}
+
+// Path gives the path of the repo config file
+func (rc RepoConfig) Path() string {
+	return filepath.Join(rc.root, FILE_NAME)
+}
+
+// Root gives the directory that holds the repo config file
+func (rc RepoConfig) Root() string {
+	return rc.root
+}
+
+// ScriptsPath gives the absolute path of the directory where scripts are kept
+func (rc RepoConfig) ScriptsPath() string {
+	return filepath.Join(rc.root, rc.ScriptsDir)
+}
+
+// ManifestPath gives the absolute path of the manifest file, or an empty string if there isn't one
+func (rc RepoConfig) ManifestPath() string {
+	if rc.Mapping.Manifest == "" {
+		return ""
+	}
+	return filepath.Join(rc.root, rc.Mapping.Manifest)
+}
@@ -0,0 +1,314 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of comparing a single script (or value) between two sources
+type Status string
+
+const (
+	StatusMatch         Status = "match"
+	StatusDiff          Status = "diff"
+	StatusMissingLocal  Status = "missing-local"  // exists in hexabase, but not locally
+	StatusMissingRemote Status = "missing-remote" // exists locally, but not in hexabase
+	StatusMissingP1     Status = "missing-p1"     // (project diff) exists in p2, but not p1
+	StatusMissingP2     Status = "missing-p2"     // (project diff) exists in p1, but not p2
+	StatusAmbiguous     Status = "ambiguous"      // more than one local file matches
+	StatusSkipped       Status = "skipped"        // not compared, such as TypeScript sources that hexabase only has the compiled output of
+	StatusInfo          Status = "info"           // differs, but is expected to, such as the names of two projects
+	StatusError         Status = "error"
+)
+
+// IsDrift is true for statuses that mean the two sources are out of sync (or couldn't be compared because of an error)
+func (s Status) IsDrift() bool {
+	return s != StatusMatch && s != StatusSkipped && s != StatusInfo
+}
+
+// report output formats
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatJUnit    = "junit"
+	FormatMarkdown = "markdown"
+)
+
+var Formats = []string{FormatText, FormatJSON, FormatJUnit, FormatMarkdown}
+
+// Result is the outcome of comparing a single script
+type Result struct {
+	ActionID   string `json:"action_id,omitempty"`
+	DisplayID  string `json:"display_id"`
+	Datastore  string `json:"datastore,omitempty"`
+	ScriptType string `json:"script_type"` // "pre", "post", "function", or for project diffs, "action", "setting", "env" and "section"
+	Status     Status `json:"status"`
+	Diff       string `json:"diff,omitempty"` // unified diff, when the status is "diff"
+	Message    string `json:"message,omitempty"`
+}
+
+// Name gives a short, human readable name for the result
+func (r Result) Name() string {
+	name := fmt.Sprintf("%s (%s)", r.DisplayID, r.ScriptType)
+	if r.Datastore != "" {
+		name += fmt.Sprintf(" [%s]", r.Datastore)
+	}
+	return name
+}
+
+// Report is the full set of results from a diff command
+type Report struct {
+	Command     string    `json:"command"`
+	Project     string    `json:"project"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Results     []Result  `json:"results"`
+}
+
+func New(command, project string) *Report {
+	return &Report{
+		Command:     command,
+		Project:     project,
+		GeneratedAt: time.Now(),
+		Results:     make([]Result, 0),
+	}
+}
+
+func (r *Report) Add(result Result) {
+	r.Results = append(r.Results, result)
+}
+
+// Counts gives the number of results for each status
+func (r Report) Counts() map[Status]int {
+	counts := make(map[Status]int)
+	for _, result := range r.Results {
+		counts[result.Status]++
+	}
+	return counts
+}
+
+// DriftFound is true if any result is drift (see Status.IsDrift)
+func (r Report) DriftFound() bool {
+	for _, result := range r.Results {
+		if result.Status.IsDrift() {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Output writes the report in the given format to the file at path, or to w if path is empty.
+// Nothing is written for the text format when path is empty, since commands already print a summary.
+func (r Report) Output(format, path string, w io.Writer) error {
+	if path != "" {
+		return r.WriteFile(format, path)
+	}
+	if format == FormatText {
+		return nil
+	}
+	return r.Write(format, w)
+}
+
+// WriteFile writes the report in the given format to a file at path.
+func (r Report) WriteFile(format, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.Write(format, f)
+}
+
+// Write writes the report in the given format.
+func (r Report) Write(format string, w io.Writer) error {
+	switch format {
+	case FormatText:
+		return r.writeText(w)
+	case FormatJSON:
+		return r.writeJSON(w)
+	case FormatJUnit:
+		return r.writeJUnit(w)
+	case FormatMarkdown:
+		return r.writeMarkdown(w)
+	}
+	return fmt.Errorf("unknown report format: %s (supported: %s)", format, strings.Join(Formats, ", "))
+}
+
+// sortedStatuses gives the statuses in the counts, in a stable order
+func sortedStatuses(counts map[Status]int) []Status {
+	statuses := make([]Status, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+	return statuses
+}
+
+func (r Report) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "%s: %s\n\n", r.Command, r.Project)
+	for _, result := range r.Results {
+		if result.Status == StatusMatch {
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s\n", strings.ToUpper(string(result.Status)), result.Name())
+		if result.Message != "" {
+			fmt.Fprintf(w, "  %s\n", result.Message)
+		}
+	}
+	fmt.Fprintln(w)
+	counts := r.Counts()
+	for _, status := range sortedStatuses(counts) {
+		fmt.Fprintf(w, "%s: %v\n", status, counts[status])
+	}
+	_, err := fmt.Fprintf(w, "total: %v\n", len(r.Results))
+	return err
+}
+
+func (r Report) writeJSON(w io.Writer) error {
+	out := struct {
+		Report
+		Summary map[Status]int `json:"summary"`
+	}{
+		Report:  r,
+		Summary: r.Counts(),
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func (r Report) writeJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:      fmt.Sprintf("%s: %s", r.Command, r.Project),
+		Timestamp: r.GeneratedAt.Format(time.RFC3339),
+	}
+	for _, result := range r.Results {
+		className := result.Datastore
+		if className == "" {
+			className = result.ScriptType
+		}
+		testCase := junitTestCase{
+			Name:      result.Name(),
+			ClassName: className,
+		}
+		message := string(result.Status)
+		if result.Message != "" {
+			message += ": " + result.Message
+		}
+		switch result.Status {
+		case StatusMatch, StatusInfo:
+		case StatusSkipped:
+			testCase.Skipped = &junitMessage{Message: message, Type: string(result.Status)}
+			suite.Skipped++
+		case StatusError:
+			testCase.Error = &junitMessage{Message: message, Type: string(result.Status), Body: result.Diff}
+			suite.Errors++
+		default:
+			testCase.Failure = &junitMessage{Message: message, Type: string(result.Status), Body: result.Diff}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Tests = len(suite.TestCases)
+
+	suites := junitTestSuites{
+		Name:       r.Command,
+		Tests:      suite.Tests,
+		Failures:   suite.Failures,
+		Errors:     suite.Errors,
+		Skipped:    suite.Skipped,
+		TestSuites: []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func (r Report) writeMarkdown(w io.Writer) error {
+	fmt.Fprintf(w, "## %s: %s\n\n", r.Command, r.Project)
+
+	counts := r.Counts()
+	fmt.Fprintln(w, "| Status | Count |")
+	fmt.Fprintln(w, "| --- | --- |")
+	for _, status := range sortedStatuses(counts) {
+		fmt.Fprintf(w, "| %s | %v |\n", status, counts[status])
+	}
+	fmt.Fprintf(w, "| **total** | %v |\n\n", len(r.Results))
+
+	if !r.DriftFound() {
+		_, err := fmt.Fprintln(w, "No differences found.")
+		return err
+	}
+
+	fmt.Fprintln(w, "| Script | Type | Datastore | Status | Message |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- | --- |")
+	for _, result := range r.Results {
+		if result.Status == StatusMatch {
+			continue
+		}
+		fmt.Fprintf(w, "| `%s` | %s | %s | %s | %s |\n", result.DisplayID, result.ScriptType, result.Datastore, result.Status, strings.ReplaceAll(result.Message, "|", "\\|"))
+	}
+
+	for _, result := range r.Results {
+		if result.Diff == "" {
+			continue
+		}
+		fmt.Fprintf(w, "\n<details>\n<summary>%s</summary>\n\n```diff\n%s```\n\n</details>\n", result.Name(), result.Diff)
+	}
+	return nil
+}
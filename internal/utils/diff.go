@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
@@ -52,3 +53,84 @@ func truncateSection(s string) string {
 
 	return strings.Join(truncated, "\n")
 }
+
+// GetUnifiedDiff gives a plain (uncolored) line diff of s1 and s2 in the unified format, which is easier to read in reports than GetDiff.
+// An empty string is returned if there are no differences.
+func GetUnifiedDiff(s1, s2, name1, name2 string) string {
+	const contextLines = 3
+
+	dmp := diffmatchpatch.New()
+	chars1, chars2, lineArray := dmp.DiffLinesToChars(s1, s2)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), lineArray)
+
+	type diffLine struct {
+		op      byte
+		text    string
+		oldLine int
+		newLine int
+	}
+	lines := make([]diffLine, 0)
+	oldLine, newLine := 1, 1
+	for _, diff := range diffs {
+		if diff.Text == "" {
+			continue
+		}
+		op := byte(' ')
+		if diff.Type == diffmatchpatch.DiffInsert {
+			op = '+'
+		} else if diff.Type == diffmatchpatch.DiffDelete {
+			op = '-'
+		}
+		for _, text := range strings.Split(strings.TrimSuffix(diff.Text, "\n"), "\n") {
+			lines = append(lines, diffLine{op: op, text: text, oldLine: oldLine, newLine: newLine})
+			if op != '+' {
+				oldLine++
+			}
+			if op != '-' {
+				newLine++
+			}
+		}
+	}
+
+	var out strings.Builder
+	i := 0
+	for i < len(lines) {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+		// a hunk continues until there is a long enough run of unchanged lines
+		lastChange := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].op != ' ' {
+				lastChange = j
+			} else if j-lastChange > 2*contextLines {
+				break
+			}
+		}
+		start := max(0, i-contextLines)
+		end := min(len(lines), lastChange+contextLines+1)
+
+		oldCount, newCount := 0, 0
+		for _, line := range lines[start:end] {
+			if line.op != '+' {
+				oldCount++
+			}
+			if line.op != '-' {
+				newCount++
+			}
+		}
+		if out.Len() == 0 {
+			out.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", name1, name2))
+		}
+		out.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", lines[start].oldLine, oldCount, lines[start].newLine, newCount))
+		for _, line := range lines[start:end] {
+			out.WriteByte(line.op)
+			out.WriteString(line.text)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+
+	return out.String()
+}
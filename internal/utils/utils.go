@@ -2,12 +2,15 @@ package utils
 
 import (
 	"bufio"
+	"context"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/fatih/color"
+	"golang.org/x/term"
 )
 
 var (
@@ -19,23 +22,72 @@ var (
 	ColorHint    = color.New(color.FgHiBlack)
 )
 
-func GetInput(prompt string) string {
+// prompts are written to stderr, since they are for whoever is at the terminal;
+// stdout may be piped somewhere, such as when it's used for a machine-readable report.
+
+// GetInput reads a word of input, after showing the prompt
+func GetInput(prompt string) (string, error) {
 	var input string
-	fmt.Print(prompt, ": ")
-	_, err := fmt.Scanln(&input)
-	if err != nil {
-		log.Fatal("failed to read input:", err)
+	fmt.Fprint(os.Stderr, prompt, ": ")
+	if _, err := fmt.Scanln(&input); err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return input, nil
+}
+
+// GetInputDefault reads a line of input, and gives def if nothing is entered. The default is shown in the prompt.
+func GetInputDefault(prompt, def string) string {
+	if def != "" {
+		prompt += " " + ColorHint.Sprintf("(%s)", def)
+	}
+	fmt.Fprint(os.Stderr, prompt, ": ")
+	input := strings.TrimSpace(readLine())
+	if input == "" {
+		return def
 	}
 	return input
 }
 
-// returns True if Yes, False otherwise. All prompts will have "[Y/n]" added to it.
+// readLine reads stdin up to the next newline. stdin is read a byte at a time (like fmt.Scanln),
+// so nothing is buffered away from later prompts.
+func readLine() string {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n == 0 || err != nil || b[0] == '\n' {
+			return string(line)
+		}
+		line = append(line, b[0])
+	}
+}
+
+// GetSecretInput reads input without echoing it, such as a password. An error is returned if stdin isn't a terminal.
+func GetSecretInput(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("can't prompt for %s: stdin is not a terminal", strings.ToLower(prompt))
+	}
+	fmt.Fprint(os.Stderr, prompt, ": ")
+	input, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(input), nil
+}
+
+// returns True if Yes, False otherwise (including when input can't be read). All prompts will have "[Y/n]" added to it.
 func YesOrNo(prompt string) bool {
 	if prompt != "" {
 		prompt += " "
 	}
 	prompt += "[Y/n]"
-	userInput := strings.ToLower(GetInput(prompt))
+	userInput, err := GetInput(prompt)
+	if err != nil {
+		return false
+	}
+	userInput = strings.ToLower(userInput)
 
 	if userInput == "y" || userInput == "yes" {
 		return true
@@ -44,31 +96,74 @@ func YesOrNo(prompt string) bool {
 }
 
 func EnterToContinue() {
-	fmt.Println("\nPress Enter to continue...")
+	fmt.Fprintln(os.Stderr, "\nPress Enter to continue...")
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
 }
 
+// InterruptContext gives a context that is cancelled on the first Ctrl-C, so work in progress can be wrapped up.
+// A second Ctrl-C quits right away, as usual.
+func InterruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupts:
+			ColorWarn.Fprintln(os.Stderr, "\nInterrupted; finishing up (press Ctrl-C again to quit)")
+			cancel()
+		case <-ctx.Done():
+		}
+		// back to the default behaviour
+		signal.Stop(interrupts)
+	}()
+	return ctx, cancel
+}
+
+// Stdout is where messages are written by default. Unlike os.Stdout, colors work with it on every platform.
+func Stdout() io.Writer {
+	return color.Output
+}
+
+// Stderr is stderr, with colors working on every platform
+func Stderr() io.Writer {
+	return color.Error
+}
+
 func Warn(header, desc string) {
-	ColorWarn.Println("\n**Warning!", header)
-	ColorWarn.Println("  " + desc)
+	WarnTo(color.Output, header, desc)
 }
 
 func Error(header, desc string) {
-	ColorError.Println("\n!!ERROR:", header)
-	ColorError.Println("  " + desc)
+	ErrorTo(color.Output, header, desc)
 }
 
 func Info(header, desc string) {
-	ColorInfo.Println("\nⓘ Info:", header)
-	ColorInfo.Println("  " + desc)
+	InfoTo(color.Output, header, desc)
 }
 
-func Fatal(header, desc string) {
-	ColorFatal.Println("\nx FATAL:", header)
-	ColorFatal.Println("  " + desc)
-	os.Exit(1)
+func Hint(text string) {
+	HintTo(color.Output, text)
 }
 
-func Hint(text string) {
-	ColorHint.Println("\n" + text)
+// WarnTo is Warn, written to w. The *To functions are for commands that keep their messages apart from other output.
+func WarnTo(w io.Writer, header, desc string) {
+	ColorWarn.Fprintln(w, "\n**Warning!", header)
+	ColorWarn.Fprintln(w, "  "+desc)
+}
+
+// ErrorTo is Error, written to w
+func ErrorTo(w io.Writer, header, desc string) {
+	ColorError.Fprintln(w, "\n!!ERROR:", header)
+	ColorError.Fprintln(w, "  "+desc)
+}
+
+// InfoTo is Info, written to w
+func InfoTo(w io.Writer, header, desc string) {
+	ColorInfo.Fprintln(w, "\nⓘ Info:", header)
+	ColorInfo.Fprintln(w, "  "+desc)
+}
+
+// HintTo is Hint, written to w
+func HintTo(w io.Writer, text string) {
+	ColorHint.Fprintln(w, "\n"+text)
 }